@@ -0,0 +1,144 @@
+package summerfish
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// annotations are read from the doc comment of a handler, e.g.
+//
+//	// @summary Cancel a subscription
+//	// @tag Billing
+//	// @response 404 ErrorResponse "subscription not found"
+//	// @deprecated
+type annotations struct {
+	Summary       string
+	Description   []string
+	Tags          []string
+	Responses     map[string]OperationResponse
+	ResponseTypes map[string]NameType
+	Deprecated    bool
+}
+
+var (
+	annotationRegex         = regexp.MustCompile(`^\s*//\s*@(\w+)\s*(.*)$`)
+	responseAnnotationRegex = regexp.MustCompile(`^(\d{3}|default)(\s+([^\s"]+))?(\s+"(.*)")?\s*$`)
+)
+
+// parseAnnotations reads the comment block right above the handler, types of responses are resolved like bodies
+func (rp *RouteParser) parseAnnotations(rawLines, lines []string) (result annotations) {
+	if rp.LineNumber < 2 || rp.LineNumber > len(rawLines) {
+		return
+	}
+
+	var comments []string
+	for i := rp.LineNumber - 2; i >= 0; i-- {
+		line := strings.TrimSpace(rawLines[i])
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		comments = append([]string{line}, comments...)
+	}
+
+	for _, comment := range comments {
+		annotation := annotationRegex.FindStringSubmatch(comment)
+		if len(annotation) == 0 {
+			continue
+		}
+
+		value := strings.TrimSpace(annotation[2])
+		switch annotation[1] {
+		case "summary":
+			result.Summary = value
+		case "description":
+			result.Description = append(result.Description, value)
+		case "tag":
+			result.Tags = append(result.Tags, value)
+		case "deprecated":
+			result.Deprecated = true
+		case "response":
+			rp.parseResponseAnnotation(value, lines, &result)
+		default:
+			rp.addDiagnostic(SeverityWarning, fmt.Sprintf("unknown annotation @%s", annotation[1]))
+		}
+	}
+
+	return
+}
+
+func (rp *RouteParser) parseResponseAnnotation(value string, lines []string, result *annotations) {
+	response := responseAnnotationRegex.FindStringSubmatch(value)
+	if len(response) == 0 {
+		rp.addDiagnostic(SeverityWarning, fmt.Sprintf("invalid annotation @response %s", value))
+		return
+	}
+
+	status := response[1]
+	description := response[5]
+	if len(description) == 0 {
+		code, _ := strconv.Atoi(status)
+		description = http.StatusText(code)
+	}
+
+	if len(description) == 0 {
+		description = "successful operation"
+	}
+
+	operationResponse := OperationResponse{Description: description}
+	if varType := response[3]; len(varType) > 0 && varType != "-" {
+		isArray := strings.HasPrefix(varType, "[]")
+		bodyField := rp.resolveType(strings.TrimPrefix(varType, "[]"), strings.TrimPrefix(varType, "[]"), lines)
+		var schema SchemaParameters
+		if len(bodyField.Type) > 0 {
+			schema = SchemaParameters{Type: bodyField.Type}
+		} else {
+			schema = rp.overrides.mapInternalParameters(bodyField)
+		}
+
+		if isArray {
+			schema = SchemaParameters{Type: "array", Items: &schema}
+		}
+
+		operationResponse.Schema = &schema
+		if len(bodyField.StructName) > 0 {
+			bodyField.IsArray = isArray
+			if result.ResponseTypes == nil {
+				result.ResponseTypes = map[string]NameType{}
+			}
+
+			result.ResponseTypes[status] = bodyField
+		}
+	}
+
+	if result.Responses == nil {
+		result.Responses = map[string]OperationResponse{}
+	}
+
+	result.Responses[status] = operationResponse
+}
+
+// addAnnotations sets what was annotated, the inferred values are only kept when there is no annotation
+func (rh *RouteHolder) addAnnotations(a annotations) {
+	if len(a.Summary) > 0 {
+		rh.Summary = a.Summary
+	}
+
+	if len(a.Description) > 0 {
+		rh.Description = strings.Join(a.Description, "\n")
+	}
+
+	if len(a.Tags) > 0 {
+		rh.Tags = a.Tags
+	}
+
+	if len(a.Responses) > 0 {
+		rh.Responses = a.Responses
+		rh.ResponseTypes = a.ResponseTypes
+	}
+
+	rh.Deprecated = rh.Deprecated || a.Deprecated
+}
@@ -0,0 +1,293 @@
+package summerfish
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/format"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ClientOptions describes the package written by GenerateClient, Overrides is the registry applied to the routes
+type ClientOptions struct {
+	Package   string
+	Overrides *Overrides
+}
+
+type clientImport struct {
+	Alias string
+	Path  string
+}
+
+type clientParameter struct {
+	Name     string
+	In       string
+	Variable string
+	Field    string
+	GoType   string
+	Zero     string
+	Value    string
+	Required bool
+}
+
+type clientOperation struct {
+	Name       string
+	Method     string
+	Route      string
+	Summary    string
+	Args       string
+	PathParams []clientParameter
+	Constants  []clientParameter
+	Params     []clientParameter
+	ParamsType string
+	Body       string
+	Form       bool
+	Result     string
+}
+
+// clientImports are the packages imported by every generated client, no identifier can shadow them
+var clientImports = []string{"bytes", "context", "json", "fmt", "io", "ioutil", "http", "url", "strings"}
+
+// clientGenerator names the imports of the reused types and the identifiers of the generated code,
+// the aliases of the imports and the variables of the methods never share a name
+type clientGenerator struct {
+	imports   map[string]string
+	aliases   map[string]bool
+	variables map[string]bool
+	packages  map[string]string
+}
+
+// GenerateClient writes a typed Go client with one method per route, named after RouteHolder.Name.
+// Bodies and responses use the original Go types, imported from their package, responses without
+// a known type are returned as json.RawMessage. Every method takes a context and the http.Client is pluggable.
+func GenerateClient(routes []RouteHolder, options ClientOptions) (source []byte, err error) {
+	g := &clientGenerator{imports: map[string]string{}, aliases: map[string]bool{}, variables: map[string]bool{}, packages: map[string]string{}}
+	for _, reserved := range clientImports {
+		g.aliases[reserved] = true
+	}
+
+	data := struct {
+		Package    string
+		Imports    []clientImport
+		Operations []clientOperation
+	}{Package: options.Package}
+
+	names := map[string]bool{}
+	for _, generated := range codegenOperations(routes, options.Overrides) {
+		operation := g.operation(generated)
+		//a handler served by several routes gets a method per route
+		for name, i := operation.Name, 2; names[operation.Name]; i++ {
+			operation.Name = fmt.Sprintf("%s%d", name, i)
+		}
+
+		if len(operation.ParamsType) > 0 {
+			operation.ParamsType = operation.Name + "Params"
+			operation.Args = strings.Replace(operation.Args, "params Params", "params "+operation.ParamsType, 1)
+		}
+
+		names[operation.Name] = true
+		data.Operations = append(data.Operations, operation)
+	}
+
+	for importPath, alias := range g.imports {
+		data.Imports = append(data.Imports, clientImport{Alias: alias, Path: importPath})
+	}
+
+	sort.Slice(data.Imports, func(i, j int) bool {
+		return data.Imports[i].Path < data.Imports[j].Path
+	})
+
+	var buffer bytes.Buffer
+	err = codegenTemplates.ExecuteTemplate(&buffer, "client.go.tmpl", data)
+	if err != nil {
+		return
+	}
+
+	return format.Source(buffer.Bytes())
+}
+
+func (g *clientGenerator) operation(generated codegenOperation) (operation clientOperation) {
+	operation.Method = generated.Method
+	operation.Route = generated.Route
+	operation.Summary = generated.Summary
+	operation.Name = goIdentifier(generated.Name, true)
+	if len(operation.Name) == 0 {
+		operation.Name = goIdentifier(strings.ToLower(operation.Method)+" "+operation.Route, true)
+	}
+
+	args := []string{"ctx context.Context"}
+	variables := map[string]bool{}
+	for _, entry := range generated.Path {
+		parameter := g.parameter("path", entry)
+		parameter.Variable = goIdentifier(entry.Name, false)
+		for variables[parameter.Variable] || g.aliases[parameter.Variable] {
+			parameter.Variable += "Param"
+		}
+
+		variables[parameter.Variable] = true
+		g.variables[parameter.Variable] = true
+		operation.PathParams = append(operation.PathParams, parameter)
+		args = append(args, parameter.Variable+" "+parameter.GoType)
+	}
+
+	fields := map[string]bool{}
+	add := func(in string, entry NameType) {
+		parameter := g.parameter(in, entry)
+		if entry.IsRequired && len(entry.Enum) == 1 {
+			parameter.Value = entry.Enum[0]
+			operation.Constants = append(operation.Constants, parameter)
+			return
+		}
+
+		for fields[parameter.Field] {
+			parameter.Field += "Param"
+		}
+
+		fields[parameter.Field] = true
+		operation.Params = append(operation.Params, parameter)
+	}
+
+	for _, entry := range generated.Query {
+		add("query", entry)
+	}
+
+	for _, entry := range generated.Headers {
+		add("header", entry)
+	}
+
+	if len(operation.Params) > 0 {
+		operation.ParamsType = "Params"
+		args = append(args, "params Params")
+	}
+
+	operation.Body = "nil"
+	if generated.Form {
+		operation.Form = true
+		operation.Body = "body"
+		args = append(args, "body io.Reader", "contentType string")
+	} else if generated.Body != nil {
+		operation.Body = "body"
+		args = append(args, "body "+g.typeExpr(*generated.Body, "interface{}"))
+	}
+
+	operation.Result = "json.RawMessage"
+	if generated.Result != nil {
+		operation.Result = g.typeExpr(*generated.Result, "json.RawMessage")
+	}
+
+	operation.Args = strings.Join(args, ", ")
+	return
+}
+
+func (g *clientGenerator) parameter(in string, entry NameType) clientParameter {
+	parameter := clientParameter{Name: entry.Name, In: in, Field: goIdentifier(entry.Name, true), Required: entry.IsRequired}
+	switch inferTypeFromPattern(entry.Type, entry.Pattern) {
+	case "integer":
+		parameter.GoType, parameter.Zero = "int64", "0"
+	case "number":
+		parameter.GoType, parameter.Zero = "float64", "0"
+	case "boolean":
+		parameter.GoType, parameter.Zero = "bool", "false"
+	default:
+		parameter.GoType, parameter.Zero = "string", `""`
+	}
+
+	return parameter
+}
+
+// typeExpr references the Go type of a body through its package, fallback is used for unresolved types,
+// unexported ones and the ones declared in main packages, which can't be imported
+func (g *clientGenerator) typeExpr(entry NameType, fallback string) string {
+	dot := strings.LastIndex(entry.StructName, ".")
+	if dot < 0 || len(entry.ImportPath) == 0 || !token.IsExported(entry.StructName[dot+1:]) {
+		return fallback
+	}
+
+	name := g.packageName(entry.ImportPath)
+	if name == "main" {
+		return fallback
+	}
+
+	alias, ok := g.imports[entry.ImportPath]
+	if !ok {
+		if len(name) == 0 {
+			name = path.Base(entry.ImportPath)
+		}
+
+		base := goIdentifier(name, false)
+		alias = base
+		for i := 2; g.aliases[alias] || g.variables[alias] || token.IsKeyword(alias); i++ {
+			alias = fmt.Sprintf("%s%d", base, i)
+		}
+
+		g.aliases[alias] = true
+		g.imports[entry.ImportPath] = alias
+	}
+
+	expr := alias + "." + entry.StructName[dot+1:]
+	if entry.IsArray {
+		expr = "[]" + expr
+	}
+
+	return expr
+}
+
+func (g *clientGenerator) packageName(importPath string) string {
+	name, ok := g.packages[importPath]
+	if !ok {
+		if pkg, err := build.Import(importPath, ".", 0); err == nil {
+			name = pkg.Name
+		}
+
+		g.packages[importPath] = name
+	}
+
+	return name
+}
+
+// goIdentifier turns names such as user_id, X-Request-Id or "get /users/{id}" into UserID style identifiers,
+// avoiding the keywords, the predeclared identifiers such as nil, the variables of the generated methods and the packages they import
+func goIdentifier(name string, exported bool) string {
+	identifier := camelCase(name, exported)
+	switch identifier {
+	case "ctx", "body", "params", "result", "err", "c", "path", "query", "header", "contentType":
+		return identifier + "Param"
+	}
+
+	if token.IsKeyword(identifier) || types.Universe.Lookup(identifier) != nil || containsString(clientImports, identifier) {
+		return identifier + "Param"
+	}
+
+	return identifier
+}
+
+// camelCase joins the words of name, the identifiers starting with a digit are prefixed with N
+func camelCase(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var builder strings.Builder
+	for i, word := range words {
+		switch {
+		case i == 0 && !exported:
+			builder.WriteString(strings.ToLower(word[:1]) + word[1:])
+		case strings.EqualFold(word, "id") || strings.EqualFold(word, "url") || strings.EqualFold(word, "api"):
+			builder.WriteString(strings.ToUpper(word))
+		default:
+			builder.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+
+	identifier := builder.String()
+	if len(identifier) > 0 && unicode.IsDigit(rune(identifier[0])) {
+		identifier = "N" + identifier
+	}
+
+	return identifier
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+func runClient(args []string) (err error) {
+	flags := flag.NewFlagSet("client", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the package registering the routes")
+	output := flags.String("o", "", "output file (default stdout)")
+	pkg := flags.String("package", "", "package of the client (default the name of the output directory, or client)")
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	result, err := summerfish.AnalyzeSource(*dir, summerfish.AnalysisOptions{})
+	if err != nil {
+		return
+	}
+
+	for _, diagnostic := range result.Diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+
+	if len(*pkg) == 0 {
+		*pkg = "client"
+		if len(*output) > 0 {
+			var outputPath string
+			outputPath, err = filepath.Abs(*output)
+			if err != nil {
+				return
+			}
+
+			*pkg = filepath.Base(filepath.Dir(outputPath))
+		}
+	}
+
+	source, err := summerfish.GenerateClient(result.Routes, summerfish.ClientOptions{Package: *pkg})
+	if err != nil {
+		return
+	}
+
+	return writeOutput(*output, source)
+}
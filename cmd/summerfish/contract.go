@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+func runContract(args []string) (err error) {
+	flags := flag.NewFlagSet("contract", flag.ExitOnError)
+	output := flags.String("o", "contract_test.go", "test file to write, the spec is loaded relative to its directory")
+	pkg := flags.String("package", "", "package of the test file (default the name of its directory)")
+	handler := flags.String("handler", "", "go expression building the handler under test, e.g. NewRouter()")
+	seed := flags.Int64("seed", 0, "seed of the example requests")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: summerfish contract -handler NewRouter() [flags] <spec>")
+		flags.PrintDefaults()
+	}
+
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if flags.NArg() != 1 || len(*handler) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	scheme, err := summerfish.LoadScheme(flags.Arg(0))
+	if err != nil {
+		return
+	}
+
+	outputPath, err := filepath.Abs(*output)
+	if err != nil {
+		return
+	}
+
+	specPath, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return
+	}
+
+	//go test runs in the directory of the package, so the spec is referenced from there
+	specPath, err = filepath.Rel(filepath.Dir(outputPath), specPath)
+	if err != nil {
+		return
+	}
+
+	if len(*pkg) == 0 {
+		*pkg = filepath.Base(filepath.Dir(outputPath))
+	}
+
+	source, err := summerfish.GenerateContractTest(scheme, summerfish.ContractTestOptions{
+		Package:  *pkg,
+		Handler:  *handler,
+		SpecPath: filepath.ToSlash(specPath),
+		Seed:     *seed,
+	})
+	if err != nil {
+		return
+	}
+
+	return writeOutput(*output, source)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+func runCoverage(args []string) (err error) {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the package registering the routes")
+	format := flags.String("format", "table", "table or json")
+	output := flags.String("o", "", "output file (default stdout)")
+	threshold := flags.Float64("threshold", 0, "minimum coverage in percent, the command fails below it")
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	result, err := summerfish.AnalyzeSource(*dir, summerfish.AnalysisOptions{})
+	if err != nil {
+		return
+	}
+
+	scheme := summerfish.SchemeHolder{BasePath: "/"}
+	scheme.Build(result.Routes)
+	report := summerfish.Coverage(&scheme, result.Diagnostics)
+	var encoded []byte
+	if *format == "json" {
+		encoded, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return
+		}
+	} else {
+		encoded = []byte(report.Table())
+	}
+
+	err = writeOutput(*output, encoded)
+	if err != nil {
+		return
+	}
+
+	if report.Score < *threshold {
+		return fmt.Errorf("coverage %.1f%% is below the threshold of %.1f%%", report.Score, *threshold)
+	}
+
+	return
+}
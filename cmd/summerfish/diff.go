@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+var errBreakingChanges = errors.New("breaking changes found")
+
+func runDiff(args []string) (err error) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", "markdown", "markdown, text or json")
+	output := flags.String("o", "", "output file (default stdout)")
+	allowBreaking := flags.Bool("allow-breaking", false, "exit with success even when breaking changes are found")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: summerfish diff [flags] <previous spec> <current spec>")
+		flags.PrintDefaults()
+	}
+
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	previous, err := summerfish.LoadScheme(flags.Arg(0))
+	if err != nil {
+		return
+	}
+
+	current, err := summerfish.LoadScheme(flags.Arg(1))
+	if err != nil {
+		return
+	}
+
+	changes := summerfish.DiffSchemes(previous, current)
+	var encoded []byte
+	switch *format {
+	case "json":
+		encoded, err = json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return
+		}
+	case "text":
+		for _, change := range changes {
+			encoded = append(encoded, fmt.Sprintf("%s\t%s\n", change.Level, change.String())...)
+		}
+	default:
+		encoded = []byte(changes.Markdown())
+	}
+
+	err = writeOutput(*output, encoded)
+	if err != nil {
+		return
+	}
+
+	if changes.HasBreaking() && !*allowBreaking {
+		return errBreakingChanges
+	}
+
+	return
+}
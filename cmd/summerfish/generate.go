@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plicca/summerfish-swagger"
+	"gopkg.in/yaml.v2"
+)
+
+func runGenerate(args []string) (err error) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the package registering the routes")
+	output := flags.String("o", "", "output file, the format is taken from its extension (default stdout)")
+	format := flags.String("format", "", "json or yaml, overrides the output extension")
+	title := flags.String("title", "", "title of the API")
+	version := flags.String("version", "", "version of the API")
+	host := flags.String("host", "", "host serving the API")
+	basePath := flags.String("base-path", "/", "base path of the API")
+	schemes := flags.String("schemes", "http", "comma separated list of schemes")
+	strict := flags.Bool("strict", false, "fail when a route or type could not be resolved")
+	coverage := flags.Bool("coverage", false, "add the x-summerfish-coverage extension")
+	var overlays stringsFlag
+	flags.Var(&overlays, "overlay", "yaml or json overlay merged on top of the spec, can be repeated")
+	learned := flags.String("learned", "", "schemas saved by a SchemaLearner, filling the free-form bodies and responses")
+	examples := flags.Bool("examples", false, "generate the missing examples of the parameters, bodies and responses")
+	seed := flags.Int64("seed", 0, "seed of the generated examples")
+	var security stringsFlag
+	flags.Var(&security, "security", "security definition as name=bearer, name=basic, name=header:X-API-Key or name=query:api_key, can be repeated")
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	result, err := summerfish.AnalyzeSource(*dir, summerfish.AnalysisOptions{Strict: *strict})
+	if err != nil {
+		return
+	}
+
+	//diagnostics go to stderr so that the document can be piped
+	for _, diagnostic := range result.Diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+
+	routes := result.Routes
+
+	scheme := summerfish.SchemeHolder{
+		Host:        *host,
+		BasePath:    *basePath,
+		Schemes:     strings.Split(*schemes, ","),
+		Information: summerfish.SchemeInformation{Title: *title, Version: *version},
+	}
+
+	if len(*format) == 0 && (strings.HasSuffix(*output, ".yaml") || strings.HasSuffix(*output, ".yml")) {
+		*format = "yaml"
+	}
+
+	scheme.SecurityDefinitions, err = parseSecurityDefinitions(security)
+	if err != nil {
+		return
+	}
+
+	scheme.Build(routes)
+	if len(*learned) > 0 {
+		var schemas summerfish.LearnedSchemas
+		schemas, err = summerfish.LoadLearnedSchemas(*learned)
+		if err != nil {
+			return
+		}
+
+		schemas.Apply(&scheme)
+	}
+
+	if *examples {
+		for _, diagnostic := range scheme.AddExamples(*seed) {
+			fmt.Fprintln(os.Stderr, diagnostic)
+		}
+	}
+
+	if *coverage {
+		scheme.AddCoverage(summerfish.Coverage(&scheme, result.Diagnostics))
+	}
+
+	for _, path := range overlays {
+		var overlay summerfish.Overlay
+		overlay, err = summerfish.LoadOverlay(path)
+		if err != nil {
+			return
+		}
+
+		var diagnostics summerfish.Diagnostics
+		diagnostics, err = scheme.ApplyOverlays(overlay)
+		if err != nil {
+			return
+		}
+
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintln(os.Stderr, diagnostic)
+		}
+	}
+
+	var encoded []byte
+	if *format == "yaml" {
+		encoded, err = yaml.Marshal(&scheme)
+	} else {
+		encoded, err = json.MarshalIndent(&scheme, "", "  ")
+	}
+
+	if err != nil {
+		return
+	}
+
+	return writeOutput(*output, encoded)
+}
+
+func parseSecurityDefinitions(values []string) (definitions map[string]summerfish.SecurityScheme, err error) {
+	for _, value := range values {
+		split := strings.SplitN(value, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid security definition %s", value)
+		}
+
+		kind := strings.SplitN(split[1], ":", 2)
+		var scheme summerfish.SecurityScheme
+		switch {
+		case kind[0] == "bearer":
+			scheme = summerfish.BearerJWT()
+		case kind[0] == "basic":
+			scheme = summerfish.BasicAuth()
+		case kind[0] == "header" && len(kind) == 2:
+			scheme = summerfish.APIKeyHeader(kind[1])
+		case kind[0] == "query" && len(kind) == 2:
+			scheme = summerfish.APIKeyQuery(kind[1])
+		default:
+			return nil, fmt.Errorf("invalid security definition %s", value)
+		}
+
+		if definitions == nil {
+			definitions = map[string]summerfish.SecurityScheme{}
+		}
+
+		definitions[split[0]] = scheme
+	}
+
+	return
+}
+
+// stringsFlag collects the values of a flag that can be repeated
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func writeOutput(path string, payload []byte) (err error) {
+	if len(path) == 0 {
+		_, err = os.Stdout.Write(payload)
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+	_, err = f.Write(payload)
+	return
+}
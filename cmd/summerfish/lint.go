@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/plicca/summerfish-swagger"
+	"gopkg.in/yaml.v2"
+)
+
+var errLintIssues = errors.New("lint issues found")
+
+func runLint(args []string) (err error) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("format", "text", "text, json or sarif")
+	output := flags.String("o", "", "output file (default stdout)")
+	configPath := flags.String("config", "", "yaml or json file with the disabled rules and severity overrides")
+	disable := flags.String("disable", "", "comma separated list of rules to disable")
+	failOn := flags.String("fail-on", summerfish.SeverityError, "lowest severity that makes the command fail: error, warning, info or none")
+	listRules := flags.Bool("rules", false, "list the built-in rules and exit")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: summerfish lint [flags] <spec>")
+		flags.PrintDefaults()
+	}
+
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if *listRules {
+		for _, rule := range summerfish.LintRules {
+			fmt.Printf("%-30s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+		}
+
+		return
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := loadLintConfig(*configPath)
+	if err != nil {
+		return
+	}
+
+	if len(*disable) > 0 {
+		config.Disabled = append(config.Disabled, strings.Split(*disable, ",")...)
+	}
+
+	scheme, err := summerfish.LoadScheme(flags.Arg(0))
+	if err != nil {
+		return
+	}
+
+	issues, err := summerfish.Lint(scheme, config)
+	if err != nil {
+		return
+	}
+
+	failed := false
+	if *failOn != "none" {
+		failed, err = issues.HasSeverity(*failOn)
+		if err != nil {
+			return fmt.Errorf("-fail-on: %v", err)
+		}
+	}
+
+	var encoded []byte
+	switch *format {
+	case "json":
+		encoded, err = json.MarshalIndent(issues, "", "  ")
+	case "sarif":
+		encoded, err = issues.SARIF(flags.Arg(0))
+	default:
+		encoded = []byte(issues.Text())
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = writeOutput(*output, encoded)
+	if err != nil {
+		return
+	}
+
+	if failed {
+		return errLintIssues
+	}
+
+	return
+}
+
+func loadLintConfig(path string) (config summerfish.LintConfig, err error) {
+	if len(path) == 0 {
+		return
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(content, &config)
+	} else {
+		err = yaml.Unmarshal(content, &config)
+	}
+
+	return
+}
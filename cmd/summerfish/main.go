@@ -0,0 +1,63 @@
+// Command summerfish generates swagger documentation from the source of a gorilla mux application.
+//
+//	summerfish generate -dir ./cmd/server -o docs/swagger.json
+//	summerfish diff previous.json docs/swagger.json
+//	summerfish lint -format sarif docs/swagger.json
+//	summerfish coverage -dir ./cmd/server -threshold 80
+//	summerfish mock -seed 42 docs/swagger.json
+//	summerfish contract -handler "NewRouter()" -o contract_test.go docs/swagger.json
+//	summerfish client -dir ./cmd/server -o client/client.go
+//	summerfish typescript -dir ./cmd/server -o web/src/api.ts
+//
+// It can also be used from go:generate:
+//
+//	//go:generate go run github.com/plicca/summerfish-swagger/cmd/summerfish generate -o swagger.yaml
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"generate":   {"generate the spec of a package without running it", runGenerate},
+	"diff":       {"compare two specs and fail on breaking changes", runDiff},
+	"lint":       {"check a spec for common issues", runLint},
+	"coverage":   {"report how much of the documentation was inferred", runCoverage},
+	"mock":       {"serve generated responses for every documented operation", runMock},
+	"contract":   {"generate a test checking the router against the spec", runContract},
+	"client":     {"generate a typed Go client reusing the request and response types", runClient},
+	"typescript": {"generate TypeScript interfaces and a fetch client", runTypeScript},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "summerfish:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: summerfish <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range []string{"generate", "diff", "lint", "coverage", "mock", "contract", "client", "typescript"} {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].description)
+	}
+}
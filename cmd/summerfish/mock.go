@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+func runMock(args []string) (err error) {
+	flags := flag.NewFlagSet("mock", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the package registering the routes, used when no spec is given")
+	basePath := flags.String("base-path", "/", "base path of the API, used when no spec is given")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	configPath := flags.String("config", "", "yaml or json file with the seed, latencies and error rates per route")
+	seed := flags.Int64("seed", 0, "seed of the generated data")
+	latency := flags.Duration("latency", 0, "latency added to every response")
+	errorRate := flags.Float64("error-rate", 0, "fraction of the requests answered with an error")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: summerfish mock [flags] [spec]")
+		flags.PrintDefaults()
+	}
+
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	var options summerfish.MockOptions
+	if len(*configPath) > 0 {
+		options, err = summerfish.LoadMockOptions(*configPath)
+		if err != nil {
+			return
+		}
+	}
+
+	//flags which were set win over the config file
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			options.Seed = *seed
+		case "latency":
+			options.Latency = *latency
+		case "error-rate":
+			options.ErrorRate = *errorRate
+		}
+	})
+
+	var scheme *summerfish.SchemeHolder
+	if flags.NArg() > 0 {
+		scheme, err = summerfish.LoadScheme(flags.Arg(0))
+		if err != nil {
+			return
+		}
+	} else {
+		var result summerfish.AnalysisResult
+		result, err = summerfish.AnalyzeSource(*dir, summerfish.AnalysisOptions{})
+		if err != nil {
+			return
+		}
+
+		scheme = &summerfish.SchemeHolder{BasePath: *basePath}
+		scheme.Build(result.Routes)
+	}
+
+	fmt.Fprintf(os.Stderr, "serving %d paths on %s\n", len(scheme.Paths), *addr)
+	return http.ListenAndServe(*addr, summerfish.MockHandler(scheme, options))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+func runTypeScript(args []string) (err error) {
+	flags := flag.NewFlagSet("typescript", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the package registering the routes")
+	output := flags.String("o", "", "output file (default stdout)")
+	baseURL := flags.String("base-url", "", "default base url of the requests")
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	result, err := summerfish.AnalyzeSource(*dir, summerfish.AnalysisOptions{})
+	if err != nil {
+		return
+	}
+
+	for _, diagnostic := range result.Diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+
+	source, err := summerfish.GenerateTypeScript(result.Routes, summerfish.TypeScriptOptions{BaseURL: *baseURL})
+	if err != nil {
+		return
+	}
+
+	return writeOutput(*output, source)
+}
@@ -0,0 +1,88 @@
+package summerfish
+
+import (
+	"strings"
+)
+
+// codegenOperation is the operation of a route as the client generators render it, once the overrides are applied.
+// Query and header parameters described with overrides are added when they were not inferred, and the result is
+// taken from the first documented success response, either a Go type or the schema of a response without one.
+type codegenOperation struct {
+	Name         string
+	Method       string
+	Route        string
+	Summary      string
+	Path         []NameType
+	Query        []NameType
+	Headers      []NameType
+	Form         bool
+	Body         *NameType
+	Result       *NameType
+	ResultSchema *SchemaParameters
+	HasResult    bool
+}
+
+// codegenOperations lists the operations of the routes which have a method, in the order of the routes
+func codegenOperations(routes []RouteHolder, overrides *Overrides) (operations []codegenOperation) {
+	for _, rh := range overrides.apply(routes) {
+		if len(rh.Methods) == 0 {
+			continue
+		}
+
+		operation := codegenOperation{
+			Name:    rh.Name,
+			Method:  strings.ToUpper(rh.Methods[0]),
+			Route:   normalizeTemplate(rh.Route),
+			Summary: rh.Summary,
+			Path:    mergePathVariables(rh.Path, parseTemplateVariables(rh.Route)),
+			Query:   append([]NameType{}, rh.Query...),
+			Headers: append([]NameType{}, rh.Headers...),
+			Form:    len(rh.FormData) > 0,
+		}
+
+		for _, parameter := range rh.Parameters {
+			entry := NameType{Name: parameter.Name, Type: parameter.Type, IsRequired: parameter.Required, Enum: parameter.Enum, Pattern: parameter.Pattern}
+			switch {
+			case parameter.QueryType == "query" && !hasCodegenParameter(operation.Query, parameter.Name, false):
+				operation.Query = append(operation.Query, entry)
+			case parameter.QueryType == "header" && !hasCodegenParameter(operation.Headers, parameter.Name, true):
+				operation.Headers = append(operation.Headers, entry)
+			}
+		}
+
+		if len(rh.Body.Name) > 0 && !operation.Form {
+			body := rh.Body
+			operation.Body = &body
+		}
+
+		for _, status := range sortedKeys(rh.Responses, rh.ResponseTypes) {
+			if !strings.HasPrefix(status, "2") {
+				continue
+			}
+
+			operation.HasResult = true
+			if entry, ok := rh.ResponseTypes[status]; ok {
+				operation.Result = &entry
+			} else {
+				operation.ResultSchema = rh.Responses[status].Schema
+			}
+
+			break
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return
+}
+
+// hasCodegenParameter finds an inferred parameter, header names are case insensitive
+func hasCodegenParameter(entries []NameType, name string, isHeader bool) bool {
+	for _, entry := range entries {
+		if entry.Name == name || (isHeader && strings.EqualFold(entry.Name, name)) {
+			return true
+		}
+	}
+
+	return false
+}
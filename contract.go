@@ -0,0 +1,159 @@
+package summerfish
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+//go:embed codegen/*.tmpl
+var codegenTemplateFiles embed.FS
+
+var codegenTemplates = template.Must(template.ParseFS(codegenTemplateFiles, "codegen/*.tmpl"))
+
+// ContractTestOptions describes the test file written by GenerateContractTest.
+// Handler is the Go expression building the handler under test, e.g. NewRouter().
+type ContractTestOptions struct {
+	Package  string
+	Handler  string
+	SpecPath string
+	Seed     int64
+}
+
+// GenerateContractTest writes a go test file running contracttest.Run on the operations of the document,
+// listing them so that the operations added later show up when the file is generated again
+func GenerateContractTest(s *SchemeHolder, options ContractTestOptions) (source []byte, err error) {
+	data := struct {
+		ContractTestOptions
+		Operations []string
+	}{ContractTestOptions: options}
+
+	forEachOperation(s, func(method, path string, operation Operation) {
+		data.Operations = append(data.Operations, method+" "+path)
+	})
+
+	var buffer bytes.Buffer
+	err = codegenTemplates.ExecuteTemplate(&buffer, "contract_test.go.tmpl", data)
+	if err != nil {
+		return
+	}
+
+	return format.Source(buffer.Bytes())
+}
+
+// ExampleRequest builds a request to the documented operation, e.g. ExampleRequest(s, "get", "/users/{id}", 0),
+// filling the path, the required query and header parameters and the body with examples.
+// Operations are seeded like AddExamples so that the requests are the same on every run.
+func ExampleRequest(s *SchemeHolder, method, path string, seed int64) (request *http.Request, err error) {
+	operation, ok := s.Paths[path][strings.ToLower(method)]
+	if !ok {
+		err = fmt.Errorf("operation %s %s is not documented", method, path)
+		return
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(method + " " + path))
+	random := rand.New(rand.NewSource(seed ^ int64(hash.Sum64())))
+	var missing error
+	value := func(parameter InputParameter) string {
+		example := parameter.Example
+		if example == nil {
+			example = exampleValue(SchemaParameters{Type: parameter.Type, Enum: parameter.Enum, Pattern: parameter.Pattern}, parameter.Name, random)
+		}
+
+		//patterns too complex to generate a value for need an example in the document
+		if example == nil && missing == nil {
+			missing = fmt.Errorf("%s parameter %s has no example and none matching its pattern %s could be generated", parameter.QueryType, parameter.Name, parameter.Pattern)
+		}
+
+		return fmt.Sprint(example)
+	}
+
+	target := strings.TrimSuffix(s.BasePath, "/") + path
+	query := url.Values{}
+	header := http.Header{}
+	var body io.Reader
+	var form *multipart.Writer
+	var formBody bytes.Buffer
+	for _, parameter := range operation.Parameters {
+		switch parameter.QueryType {
+		case "path":
+			target = strings.Replace(target, "{"+parameter.Name+"}", url.PathEscape(value(parameter)), 1)
+		case "query":
+			if parameter.Required {
+				query.Set(parameter.Name, value(parameter))
+			}
+		case "header":
+			if parameter.Required {
+				header.Set(parameter.Name, value(parameter))
+			}
+		case "body":
+			example := parameter.Schema.Example
+			if example == nil {
+				example = exampleValue(parameter.Schema, parameter.Name, random)
+			}
+
+			var encoded []byte
+			encoded, err = json.Marshal(normalizeYaml(example))
+			if err != nil {
+				return
+			}
+
+			body = bytes.NewReader(encoded)
+			header.Set(contentTypeHeader, jsonContentType)
+		case "formData":
+			if form == nil {
+				form = multipart.NewWriter(&formBody)
+			}
+
+			if parameter.Type == "file" {
+				var file io.Writer
+				file, err = form.CreateFormFile(parameter.Name, parameter.Name+".txt")
+				if err != nil {
+					return
+				}
+
+				file.Write([]byte("example"))
+			} else {
+				form.WriteField(parameter.Name, value(parameter))
+			}
+		}
+	}
+
+	if missing != nil {
+		return nil, missing
+	}
+
+	if form != nil {
+		form.Close()
+		body = &formBody
+		header.Set(contentTypeHeader, form.FormDataContentType())
+	} else if body != nil && len(operation.Consumes) > 0 && !isConsumed(header.Get(contentTypeHeader), operation.Consumes) {
+		header.Set(contentTypeHeader, operation.Consumes[0])
+	}
+
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err = http.NewRequest(strings.ToUpper(method), target, body)
+	if err != nil {
+		return
+	}
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	return
+}
@@ -0,0 +1,77 @@
+// Package contracttest checks from go tests that a handler answers as documented by a summerfish document.
+// It is kept apart from summerfish so that importing the library doesn't import the testing package.
+package contracttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/plicca/summerfish-swagger"
+)
+
+// Options configures Run.
+// Prepare is called with every request, e.g. to add credentials. Operations restricts the test to the listed
+// "method path" keys, e.g. "get /users/{id}", which must all be documented. Seed changes the example values.
+type Options struct {
+	Seed       int64
+	Prepare    func(r *http.Request)
+	Operations []string
+}
+
+// Run sends an example request to the handler for every operation of the document, each in its own
+// subtest, and fails when the response status or body doesn't match the documented responses
+func Run(t *testing.T, handler http.Handler, s *summerfish.SchemeHolder, options Options) {
+	operations := options.Operations
+	if len(operations) == 0 {
+		var paths []string
+		for path := range s.Paths {
+			paths = append(paths, path)
+		}
+
+		sort.Strings(paths)
+		for _, path := range paths {
+			var methods []string
+			for method := range s.Paths[path] {
+				methods = append(methods, method)
+			}
+
+			sort.Strings(methods)
+			for _, method := range methods {
+				operations = append(operations, method+" "+path)
+			}
+		}
+	}
+
+	for _, key := range operations {
+		key := key
+		t.Run(key, func(t *testing.T) {
+			split := strings.SplitN(key, " ", 2)
+			if len(split) != 2 {
+				t.Fatalf("operation %s must be written as \"method path\"", key)
+			}
+
+			operation, ok := s.Paths[split[1]][split[0]]
+			if !ok {
+				t.Fatalf("operation %s is not documented", key)
+			}
+
+			request, err := summerfish.ExampleRequest(s, split[0], split[1], options.Seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if options.Prepare != nil {
+				options.Prepare(request)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if problem, ok := summerfish.ValidateOperationResponse(operation, request, recorder.Code, recorder.Header(), recorder.Body.Bytes()); !ok {
+				t.Errorf("%s %s answered %d: %s", request.Method, request.URL, recorder.Code, problem.Detail)
+			}
+		})
+	}
+}
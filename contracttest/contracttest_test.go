@@ -0,0 +1,83 @@
+package contracttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/plicca/summerfish-swagger"
+)
+
+func TestRun(t *testing.T) {
+	user := summerfish.SchemaParameters{Type: "object", Properties: map[string]summerfish.SchemaParameters{"id": {Type: "integer"}, "name": {Type: "string"}}}
+	scheme := &summerfish.SchemeHolder{BasePath: "/api", Paths: summerfish.PathsHolder{
+		"/users/{id}": summerfish.Method{"get": summerfish.Operation{
+			Parameters: []summerfish.InputParameter{
+				{Name: "id", QueryType: "path", Type: "integer", Required: true},
+				{Name: "fields", QueryType: "query", Type: "string", Required: true, Enum: []string{"all"}},
+				{Name: "Authorization", QueryType: "header", Type: "string"},
+			},
+			Responses: map[string]summerfish.OperationResponse{"200": {Schema: &user}, "401": {Description: "Unauthorized"}},
+		}},
+		"/users": summerfish.Method{"post": summerfish.Operation{
+			Parameters: []summerfish.InputParameter{{Name: "User", QueryType: "body", Required: true, Schema: user}},
+			Responses:  map[string]summerfish.OperationResponse{"201": {Schema: &user}},
+		}},
+	}}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": r.URL.Query().Get("fields")})
+	}).Methods("GET")
+	router.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	}).Methods("POST")
+
+	prepared := 0
+	Run(t, router, scheme, Options{Prepare: func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer token")
+		prepared++
+	}})
+
+	if prepared != 2 {
+		t.Fatal(prepared)
+	}
+
+	Run(t, summerfish.MockHandler(scheme, summerfish.MockOptions{}), scheme, Options{Seed: 3, Operations: []string{"get /users/{id}"}})
+}
+
+// TestMockPatterns runs the contract against the mock of a document with patterns, the mock answers 400,
+// which isn't documented, when the generated path or header doesn't match its pattern
+func TestMockPatterns(t *testing.T) {
+	order := summerfish.SchemaParameters{Type: "object", Properties: map[string]summerfish.SchemaParameters{
+		"id":   {Type: "string", Pattern: "^[0-9a-f]{24}$"},
+		"code": {Type: "string", Pattern: `^[A-Z]{2}-\d{3}$`},
+	}}
+
+	scheme := &summerfish.SchemeHolder{BasePath: "/api", Paths: summerfish.PathsHolder{
+		"/orders/{id}": summerfish.Method{"get": summerfish.Operation{
+			Parameters: []summerfish.InputParameter{
+				{Name: "id", QueryType: "path", Type: "string", Required: true, Pattern: "^[0-9a-f]{24}$"},
+				{Name: "X-Region", QueryType: "header", Type: "string", Required: true, Pattern: "^(eu|us)-[a-z]+-[1-9]$"},
+			},
+			Responses: map[string]summerfish.OperationResponse{"200": {Schema: &order}},
+		}},
+	}}
+
+	for seed := int64(0); seed < 5; seed++ {
+		Run(t, summerfish.MockHandler(scheme, summerfish.MockOptions{Seed: seed}), scheme, Options{Seed: seed})
+	}
+}
@@ -0,0 +1,259 @@
+package summerfish
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	CoverageHandler    = "handler"
+	CoverageName       = "name"
+	CoverageParameters = "parameters"
+	CoverageBody       = "body"
+	CoverageResponses  = "responses"
+)
+
+var coverageChecks = []string{CoverageHandler, CoverageName, CoverageParameters, CoverageBody, CoverageResponses}
+
+// OperationCoverage tells which parts of an operation were confidently inferred.
+// Checks that don't apply, e.g. the body of an operation without one, are left out.
+type OperationCoverage struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Checks map[string]bool `json:"checks"`
+	Score  float64         `json:"score"`
+}
+
+// CoverageReport holds the coverage of every operation, Score is the average of all of them in percent
+type CoverageReport struct {
+	Operations []OperationCoverage `json:"operations"`
+	Score      float64             `json:"score"`
+}
+
+// CoverageSummary is emitted in the document as the x-summerfish-coverage extension
+type CoverageSummary struct {
+	Score      float64            `json:"score"`
+	Operations int                `json:"operations"`
+	Checks     map[string]float64 `json:"checks"`
+}
+
+// Coverage measures how much of the document was inferred. Routes whose handler wasn't found, types which
+// couldn't be resolved and path variables whose type was assumed come from the diagnostics.
+func Coverage(s *SchemeHolder, diagnostics Diagnostics) (report CoverageReport) {
+	unresolvedTypes, assumedParameters := map[string]bool{}, map[string]bool{}
+	for _, diagnostic := range diagnostics {
+		switch diagnostic.Kind {
+		case DiagnosticType:
+			diagnostic.mark(s, unresolvedTypes)
+		case DiagnosticParameter:
+			diagnostic.mark(s, assumedParameters)
+		}
+	}
+
+	forEachOperation(s, func(method, path string, operation Operation) {
+		checks := map[string]bool{
+			CoverageHandler:    true,
+			CoverageName:       len(strings.TrimSpace(operation.Summary)) > 0,
+			CoverageParameters: true,
+			CoverageResponses:  false,
+		}
+
+		for _, parameter := range operation.Parameters {
+			if parameter.QueryType == "body" {
+				checks[CoverageBody] = isSchemaResolved(parameter.Schema)
+			} else if len(parameter.Type) == 0 {
+				checks[CoverageParameters] = false
+			}
+		}
+
+		//the default successful response without a body is not considered as detected
+		for status, response := range operation.Responses {
+			if response.Schema != nil || status != "200" {
+				checks[CoverageResponses] = true
+			}
+		}
+
+		//a type that wasn't found may be the body or a response, neither is trusted
+		if isMarked(unresolvedTypes, method, path) {
+			if _, ok := checks[CoverageBody]; ok {
+				checks[CoverageBody] = false
+			}
+
+			checks[CoverageResponses] = false
+		}
+
+		if isMarked(assumedParameters, method, path) {
+			checks[CoverageParameters] = false
+		}
+
+		report.add(method, path, checks)
+	})
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Kind != DiagnosticHandler {
+			continue
+		}
+
+		methods := diagnostic.Methods
+		if len(methods) == 0 {
+			methods = []string{""}
+		}
+
+		for _, method := range methods {
+			report.add(method, documentPath(s, diagnostic.Route), map[string]bool{CoverageHandler: false})
+		}
+	}
+
+	for _, operation := range report.Operations {
+		report.Score += operation.Score
+	}
+
+	if len(report.Operations) > 0 {
+		report.Score = roundPercent(report.Score / float64(len(report.Operations)))
+	}
+
+	return
+}
+
+func (report *CoverageReport) add(method, path string, checks map[string]bool) {
+	passed := 0
+	for _, ok := range checks {
+		if ok {
+			passed++
+		}
+	}
+
+	//a handler that wasn't found means nothing else could be inferred
+	total := len(checks)
+	if !checks[CoverageHandler] {
+		total = len(coverageChecks)
+	}
+
+	report.Operations = append(report.Operations, OperationCoverage{
+		Method: strings.ToUpper(method),
+		Path:   path,
+		Checks: checks,
+		Score:  roundPercent(float64(passed) * 100 / float64(total)),
+	})
+}
+
+// mark records the operations of the diagnostic, an empty method stands for all of them
+func (d Diagnostic) mark(s *SchemeHolder, operations map[string]bool) {
+	if len(d.Methods) == 0 {
+		operations[" "+documentPath(s, d.Route)] = true
+	}
+
+	for _, method := range d.Methods {
+		operations[strings.ToUpper(method)+" "+documentPath(s, d.Route)] = true
+	}
+}
+
+func isMarked(operations map[string]bool, method, path string) bool {
+	return operations[strings.ToUpper(method)+" "+path] || operations[" "+path]
+}
+
+// documentPath is the path of a route in the document, without the base path
+func documentPath(s *SchemeHolder, route string) string {
+	path := trimBasePath(route, s.BasePath)
+	if len(path) == 0 {
+		return "/"
+	}
+
+	return path
+}
+
+// isSchemaResolved requires a type everywhere, objects without properties come from structs that weren't found
+func isSchemaResolved(schema SchemaParameters) bool {
+	if len(schema.Type) == 0 || (schema.Type == "object" && len(schema.Properties) == 0) {
+		return false
+	}
+
+	if schema.Items != nil {
+		return isSchemaResolved(*schema.Items)
+	}
+
+	for _, property := range schema.Properties {
+		if !isSchemaResolved(property) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func roundPercent(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// Summary aggregates the report, each check is the percentage of operations where it applies and passed
+func (report CoverageReport) Summary() CoverageSummary {
+	summary := CoverageSummary{Score: report.Score, Operations: len(report.Operations), Checks: map[string]float64{}}
+	for _, check := range coverageChecks {
+		passed, total := 0, 0
+		for _, operation := range report.Operations {
+			ok, applies := operation.Checks[check]
+			if !applies && operation.Checks[CoverageHandler] {
+				continue
+			}
+
+			total++
+			if ok {
+				passed++
+			}
+		}
+
+		if total > 0 {
+			summary.Checks[check] = roundPercent(float64(passed) * 100 / float64(total))
+		}
+	}
+
+	return summary
+}
+
+// Table renders one row per operation with the checks that passed, followed by the total score
+func (report CoverageReport) Table() string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "METHOD\tPATH\t"+strings.ToUpper(strings.Join(coverageChecks, "\t"))+"\tSCORE")
+	for _, operation := range report.Operations {
+		row := []string{operation.Method, operation.Path}
+		for _, check := range coverageChecks {
+			ok, applies := operation.Checks[check]
+			switch {
+			case !applies && operation.Checks[CoverageHandler]:
+				row = append(row, "-")
+			case ok:
+				row = append(row, "yes")
+			default:
+				row = append(row, "no")
+			}
+		}
+
+		fmt.Fprintf(writer, "%s\t%.1f%%\n", strings.Join(row, "\t"), operation.Score)
+	}
+
+	writer.Flush()
+	fmt.Fprintf(&builder, "\ncoverage: %.1f%% of %d operations\n", report.Score, len(report.Operations))
+	return builder.String()
+}
+
+// AddCoverage emits the summary of the report in the document and the score of each operation
+func (s *SchemeHolder) AddCoverage(report CoverageReport) {
+	summary := report.Summary()
+	s.Coverage = &summary
+	for _, operation := range report.Operations {
+		methods, ok := s.Paths[operation.Path]
+		if !ok {
+			continue
+		}
+
+		method := strings.ToLower(operation.Method)
+		if documented, ok := methods[method]; ok {
+			score := operation.Score
+			documented.Coverage = &score
+			methods[method] = documented
+		}
+	}
+}
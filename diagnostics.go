@@ -0,0 +1,159 @@
+package summerfish
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	DiagnosticHandler   = "handler"
+	DiagnosticType      = "type"
+	DiagnosticRoute     = "route"
+	DiagnosticParameter = "parameter"
+)
+
+// Diagnostic explains why a route or a type could not be fully documented
+type Diagnostic struct {
+	Severity string   `json:"severity"`
+	Kind     string   `json:"kind"`
+	Route    string   `json:"route,omitempty"`
+	Methods  []string `json:"methods,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Reason   string   `json:"reason"`
+}
+
+type Diagnostics []Diagnostic
+
+// AnalysisOptions configures AnalyzeRouter and AnalyzeSource.
+// In strict mode the analysis fails when any route or type could not be resolved.
+// The types with a schema in Overrides, DefaultOverrides when nil, are not resolved from the source.
+type AnalysisOptions struct {
+	Strict    bool
+	Overrides *Overrides
+}
+
+type AnalysisResult struct {
+	Routes      []RouteHolder
+	Diagnostics Diagnostics
+}
+
+// DiagnosticsError is returned in strict mode, it holds the diagnostics with error severity
+type DiagnosticsError struct {
+	Diagnostics Diagnostics
+}
+
+// AnalyzeRouter works like GetInfoFromRouter and also reports what could not be resolved
+func AnalyzeRouter(r *mux.Router, options AnalysisOptions) (result AnalysisResult, err error) {
+	holder := RouteParserHolder{}
+	err = r.Walk(holder.walkGorillaMuxRoutes)
+	if err != nil {
+		return
+	}
+
+	result, err = getInfoFromParsers(holder.routeParsers, options.Overrides)
+	result.Diagnostics = append(holder.Diagnostics, result.Diagnostics...)
+	return result, options.check(result, err)
+}
+
+// AnalyzeSource works like GetInfoFromSource and also reports what could not be resolved
+func AnalyzeSource(dir string, options AnalysisOptions) (result AnalysisResult, err error) {
+	analyzer, err := newSourceAnalyzer(dir)
+	if err != nil {
+		return
+	}
+
+	result, err = getInfoFromParsers(analyzer.routeParsers, options.Overrides)
+	result.Diagnostics = append(analyzer.diagnostics, result.Diagnostics...)
+	return result, options.check(result, err)
+}
+
+func (options AnalysisOptions) check(result AnalysisResult, err error) error {
+	if err != nil || !options.Strict {
+		return err
+	}
+
+	var errors Diagnostics
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Severity == SeverityError {
+			errors = append(errors, diagnostic)
+		}
+	}
+
+	if len(errors) > 0 {
+		return &DiagnosticsError{Diagnostics: errors}
+	}
+
+	return nil
+}
+
+func (e *DiagnosticsError) Error() string {
+	lines := []string{fmt.Sprintf("summerfish: %d routes or types could not be resolved", len(e.Diagnostics))}
+	for _, diagnostic := range e.Diagnostics {
+		lines = append(lines, diagnostic.String())
+	}
+
+	return strings.Join(lines, "\n\t")
+}
+
+func (d Diagnostic) String() string {
+	location := d.Route
+	if len(d.Methods) > 0 {
+		location = strings.Join(d.Methods, ",") + " " + d.Route
+	}
+
+	if len(d.File) > 0 && d.Line > 0 {
+		location += fmt.Sprintf(" (%s:%d)", filepath.Base(d.File), d.Line)
+	} else if len(d.File) > 0 {
+		location += fmt.Sprintf(" (%s)", filepath.Base(d.File))
+	}
+
+	return fmt.Sprintf("%s: %s: %s", d.Severity, strings.TrimSpace(location), d.Reason)
+}
+
+// Count returns how many diagnostics have the given severity
+func (diagnostics Diagnostics) Count(severity string) (count int) {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == severity {
+			count++
+		}
+	}
+
+	return
+}
+
+func hasSubrouter(route *mux.Route) bool {
+	matchers := reflect.ValueOf(route).Elem().FieldByName("matchers")
+	for i := 0; matchers.IsValid() && i < matchers.Len(); i++ {
+		if matchers.Index(i).Elem().Type() == reflect.TypeOf(&mux.Router{}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getHandlerName(handler http.Handler, namePath RoutePath) string {
+	if len(namePath.RelativePath) > 0 {
+		return namePath.RelativePath
+	}
+
+	return reflect.TypeOf(handler).String()
+}
+
+func (rp *RouteParser) addDiagnostic(severity, reason string) {
+	rp.Diagnostics = append(rp.Diagnostics, Diagnostic{
+		Severity: severity,
+		Kind:     DiagnosticType,
+		Route:    rp.Route,
+		Methods:  rp.Methods,
+		File:     rp.FullPath,
+		Line:     rp.LineNumber,
+		Reason:   reason,
+	})
+}
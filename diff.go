@@ -0,0 +1,318 @@
+package summerfish
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ChangeBreaking    = "breaking"
+	ChangeNonBreaking = "non-breaking"
+)
+
+// SpecChange is one difference found between two documents
+type SpecChange struct {
+	Level   string `json:"level"`
+	Method  string `json:"method,omitempty"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type SpecChanges []SpecChange
+
+// LoadScheme reads a json or yaml document generated by summerfish
+func LoadScheme(path string) (s *SchemeHolder, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	s = &SchemeHolder{}
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(content, s)
+	} else {
+		err = json.Unmarshal(content, s)
+	}
+
+	return
+}
+
+// DiffSchemes compares two documents and classifies the changes by whether they break existing clients
+func DiffSchemes(previous, current *SchemeHolder) (changes SpecChanges) {
+	if previous.BasePath != current.BasePath {
+		changes.add(ChangeBreaking, "", previous.BasePath, fmt.Sprintf("base path changed to %s", current.BasePath))
+	}
+
+	for _, path := range sortedKeys(previous.Paths, current.Paths) {
+		previousMethods, wasPresent := previous.Paths[path]
+		currentMethods, isPresent := current.Paths[path]
+		if !isPresent {
+			changes.add(ChangeBreaking, "", path, "path removed")
+			continue
+		}
+
+		if !wasPresent {
+			changes.add(ChangeNonBreaking, "", path, "path added")
+			continue
+		}
+
+		for _, method := range sortedKeys(previousMethods, currentMethods) {
+			previousOperation, wasPresent := previousMethods[method]
+			currentOperation, isPresent := currentMethods[method]
+			if !isPresent {
+				changes.add(ChangeBreaking, method, path, "operation removed")
+			} else if !wasPresent {
+				changes.add(ChangeNonBreaking, method, path, "operation added")
+			} else {
+				changes = append(changes, diffOperations(method, path, previousOperation, currentOperation)...)
+			}
+		}
+	}
+
+	return
+}
+
+func diffOperations(method, path string, previous, current Operation) (changes SpecChanges) {
+	previousParameters := map[string]InputParameter{}
+	for _, parameter := range previous.Parameters {
+		previousParameters[parameter.QueryType+" "+parameter.Name] = parameter
+	}
+
+	currentParameters := map[string]bool{}
+	for _, parameter := range current.Parameters {
+		key := parameter.QueryType + " " + parameter.Name
+		currentParameters[key] = true
+		location := fmt.Sprintf("parameter %s in %s", parameter.Name, parameter.QueryType)
+		old, ok := previousParameters[key]
+		if !ok {
+			if parameter.Required {
+				changes.add(ChangeBreaking, method, path, location+" added as required")
+			} else {
+				changes.add(ChangeNonBreaking, method, path, location+" added")
+			}
+
+			continue
+		}
+
+		if parameter.Required && !old.Required {
+			changes.add(ChangeBreaking, method, path, location+" became required")
+		} else if !parameter.Required && old.Required {
+			changes.add(ChangeNonBreaking, method, path, location+" became optional")
+		}
+
+		if old.Type != parameter.Type {
+			changes.add(ChangeBreaking, method, path, fmt.Sprintf("%s changed type from %s to %s", location, old.Type, parameter.Type))
+		}
+
+		if old.Pattern != parameter.Pattern && len(parameter.Pattern) > 0 {
+			changes.add(ChangeBreaking, method, path, fmt.Sprintf("%s changed pattern to %s", location, parameter.Pattern))
+		}
+
+		diffEnums(&changes, method, path, location, old.Enum, parameter.Enum, false)
+		if parameter.QueryType == "body" {
+			diffSchemas(&changes, method, path, "request body", old.Schema, parameter.Schema, false)
+		}
+	}
+
+	for key, parameter := range previousParameters {
+		if !currentParameters[key] {
+			changes.add(ChangeNonBreaking, method, path, fmt.Sprintf("parameter %s in %s removed", parameter.Name, parameter.QueryType))
+		}
+	}
+
+	for _, consumes := range previous.Consumes {
+		if len(current.Consumes) > 0 && !containsString(current.Consumes, consumes) {
+			changes.add(ChangeBreaking, method, path, fmt.Sprintf("content type %s no longer accepted", consumes))
+		}
+	}
+
+	for _, status := range sortedKeys(previous.Responses, current.Responses) {
+		old, wasPresent := previous.Responses[status]
+		response, isPresent := current.Responses[status]
+		location := "response " + status
+		switch {
+		case !isPresent:
+			changes.add(ChangeBreaking, method, path, location+" removed")
+		case !wasPresent:
+			changes.add(ChangeNonBreaking, method, path, location+" added")
+		case old.Schema != nil && response.Schema == nil:
+			changes.add(ChangeBreaking, method, path, location+" body removed")
+		case old.Schema != nil:
+			diffSchemas(&changes, method, path, location, *old.Schema, *response.Schema, true)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Message < changes[j].Message
+	})
+
+	return
+}
+
+// diffSchemas compares bodies, removing fields only breaks clients when they read them from a response,
+// while requiring them only breaks the clients sending them in a request
+func diffSchemas(changes *SpecChanges, method, path, location string, previous, current SchemaParameters, isResponse bool) {
+	if previous.Type != current.Type {
+		changes.add(ChangeBreaking, method, path, fmt.Sprintf("%s changed type from %s to %s", location, previous.Type, current.Type))
+		return
+	}
+
+	diffEnums(changes, method, path, location, previous.Enum, current.Enum, isResponse)
+	if previous.Items != nil && current.Items != nil {
+		diffSchemas(changes, method, path, location+"[]", *previous.Items, *current.Items, isResponse)
+	}
+
+	for _, name := range sortedKeys(previous.Properties, current.Properties) {
+		old, wasPresent := previous.Properties[name]
+		property, isPresent := current.Properties[name]
+		propertyLocation := location + "." + name
+		switch {
+		case !isPresent && isResponse:
+			changes.add(ChangeBreaking, method, path, propertyLocation+" removed")
+		case !isPresent:
+			changes.add(ChangeNonBreaking, method, path, propertyLocation+" removed")
+		case !wasPresent && !isResponse && containsString(current.Required, name):
+			changes.add(ChangeBreaking, method, path, propertyLocation+" added as required")
+		case !wasPresent:
+			changes.add(ChangeNonBreaking, method, path, propertyLocation+" added")
+		default:
+			diffRequired(changes, method, path, propertyLocation, containsString(previous.Required, name), containsString(current.Required, name), isResponse)
+			diffSchemas(changes, method, path, propertyLocation, old, property, isResponse)
+		}
+	}
+}
+
+// diffRequired flags request properties becoming required and response properties becoming optional
+func diffRequired(changes *SpecChanges, method, path, location string, wasRequired, isRequired, isResponse bool) {
+	switch {
+	case isRequired && !wasRequired:
+		level := ChangeBreaking
+		if isResponse {
+			level = ChangeNonBreaking
+		}
+
+		changes.add(level, method, path, location+" became required")
+	case !isRequired && wasRequired:
+		level := ChangeNonBreaking
+		if isResponse {
+			level = ChangeBreaking
+		}
+
+		changes.add(level, method, path, location+" became optional")
+	}
+}
+
+// diffEnums flags values removed from requests and values added to responses, both of which clients can't handle
+func diffEnums(changes *SpecChanges, method, path, location string, previous, current []string, isResponse bool) {
+	if len(previous) == 0 && len(current) > 0 && !isResponse {
+		changes.add(ChangeBreaking, method, path, fmt.Sprintf("%s restricted to %s", location, strings.Join(current, ", ")))
+		return
+	}
+
+	for _, value := range previous {
+		if len(current) > 0 && !containsString(current, value) {
+			level := ChangeBreaking
+			if isResponse {
+				level = ChangeNonBreaking
+			}
+
+			changes.add(level, method, path, fmt.Sprintf("%s no longer has value %s", location, value))
+		}
+	}
+
+	for _, value := range current {
+		if len(previous) > 0 && !containsString(previous, value) {
+			level := ChangeNonBreaking
+			if isResponse {
+				level = ChangeBreaking
+			}
+
+			changes.add(level, method, path, fmt.Sprintf("%s has new value %s", location, value))
+		}
+	}
+}
+
+func (changes *SpecChanges) add(level, method, path, message string) {
+	*changes = append(*changes, SpecChange{Level: level, Method: strings.ToUpper(method), Path: path, Message: message})
+}
+
+func (changes SpecChanges) HasBreaking() bool {
+	for _, change := range changes {
+		if change.Level == ChangeBreaking {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Markdown renders the changes as a changelog, breaking changes first
+func (changes SpecChanges) Markdown() string {
+	var builder strings.Builder
+	builder.WriteString("# API changes\n")
+	if len(changes) == 0 {
+		builder.WriteString("\nNo changes.\n")
+		return builder.String()
+	}
+
+	for _, section := range []struct {
+		level string
+		title string
+	}{{ChangeBreaking, "Breaking changes"}, {ChangeNonBreaking, "Other changes"}} {
+		header := false
+		for _, change := range changes {
+			if change.Level != section.level {
+				continue
+			}
+
+			if !header {
+				builder.WriteString("\n## " + section.title + "\n\n")
+				header = true
+			}
+
+			builder.WriteString("- " + change.String() + "\n")
+		}
+	}
+
+	return builder.String()
+}
+
+func (change SpecChange) String() string {
+	if len(change.Method) == 0 {
+		return fmt.Sprintf("`%s`: %s", change.Path, change.Message)
+	}
+
+	return fmt.Sprintf("`%s %s`: %s", change.Method, change.Path, change.Message)
+}
+
+// sortedKeys merges the keys of maps with string keys, e.g. the paths of both documents
+func sortedKeys(maps ...interface{}) (keys []string) {
+	seen := map[string]bool{}
+	for _, m := range maps {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			if !seen[key.String()] {
+				seen[key.String()] = true
+				keys = append(keys, key.String())
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return
+}
+
+func containsString(values []string, value string) bool {
+	for _, entry := range values {
+		if entry == value {
+			return true
+		}
+	}
+
+	return false
+}
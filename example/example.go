@@ -4,7 +4,6 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -60,25 +59,17 @@ func IsAlive(w http.ResponseWriter, r *http.Request) {
 }
 
 func GenerateSwaggerDocsAndEndpoints(router *mux.Router, endpoint string) (err error) {
-	routerInformation, err := summerfish.GetInfoFromRouter(router)
+	report, err := summerfish.Setup(router, summerfish.Config{
+		Schemes:          []string{"http", "https"},
+		Host:             endpoint,
+		Information:      summerfish.SchemeInformation{Title: "SummerFish Demo", Version: "0.0.1"},
+		SwaggerFileRoute: "/docs/swagger.json",
+		SwaggerUIRoute:   "/docs/",
+	})
 	if err != nil {
 		return
 	}
 
-	scheme := summerfish.SchemeHolder{Schemes: []string{"http", "https"}, Host: endpoint, BasePath: "/", Information: summerfish.SchemeInformation{Title: "SummerFish Demo", Version: "0.0.1"}}
-
-	swaggerFilePathYaml, err := filepath.Abs("swaggerui/swagger.yaml")
-	if err != nil {
-		return
-	}
-
-	err = scheme.GenerateSwaggerYaml(routerInformation, swaggerFilePathYaml)
-	if err != nil {
-		return
-	}
-
-	log.Println("Swagger documentation generated")
-	swaggerUIRoute := "/docs/"
-	router.PathPrefix(swaggerUIRoute).Handler(http.StripPrefix(swaggerUIRoute, http.FileServer(http.Dir("swaggerui/"))))
+	log.Printf("Swagger documentation generated for %d operations", report.Operations)
 	return
 }
@@ -0,0 +1,400 @@
+package summerfish
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+// DiagnosticExample is the kind of the warnings raised for the examples that couldn't be generated
+const DiagnosticExample = "example"
+
+var (
+	exampleFirstNames = []string{"Ada", "Grace", "Alan", "Linus", "Margaret", "Dennis"}
+	exampleLastNames  = []string{"Lovelace", "Hopper", "Turing", "Torvalds", "Hamilton", "Ritchie"}
+	exampleCities     = []string{"Lisbon", "Porto", "Berlin", "London", "Toronto", "Osaka"}
+	exampleWords      = []string{"summer", "fish", "river", "stone", "cloud", "ember", "harbor", "meadow"}
+)
+
+// Example generates a realistic value of the schema, name is the property or parameter it describes
+// and the same seed always produces the same value
+func Example(schema SchemaParameters, name string, seed int64) interface{} {
+	return exampleValue(schema, name, rand.New(rand.NewSource(seed)))
+}
+
+// AddExamples sets the examples of the parameters, bodies and JSON responses which don't have one yet,
+// each operation is seeded from the seed and its path so that adding operations doesn't change the others.
+// Strings whose pattern is too complex to generate a match for are left without an example and reported.
+func (s *SchemeHolder) AddExamples(seed int64) (diagnostics Diagnostics) {
+	for _, path := range sortedKeys(s.Paths) {
+		methods := s.Paths[path]
+		for _, method := range sortedKeys(methods) {
+			operation := methods[method]
+			report := func(location string, patterns []string) {
+				for _, pattern := range patterns {
+					diagnostics = append(diagnostics, Diagnostic{
+						Severity: SeverityWarning,
+						Kind:     DiagnosticExample,
+						Route:    strings.TrimSuffix(s.BasePath, "/") + path,
+						Methods:  []string{strings.ToUpper(method)},
+						Reason:   fmt.Sprintf("no example matching the pattern %s of %s could be generated", pattern, location),
+					})
+				}
+			}
+
+			hash := fnv.New64a()
+			hash.Write([]byte(method + " " + path))
+			random := rand.New(rand.NewSource(seed ^ int64(hash.Sum64())))
+			parameters := make([]InputParameter, len(operation.Parameters))
+			for i, parameter := range operation.Parameters {
+				switch {
+				case parameter.QueryType == "body" && parameter.Schema.Example == nil:
+					parameter.Schema.Example = exampleValue(parameter.Schema, parameter.Name, random)
+					report("the body", unsupportedPatterns(parameter.Schema))
+				case parameter.QueryType != "body" && parameter.Type != "file" && parameter.Example == nil:
+					schema := SchemaParameters{Type: parameter.Type, Enum: parameter.Enum, Pattern: parameter.Pattern}
+					parameter.Example = exampleValue(schema, parameter.Name, random)
+					report(parameter.QueryType+" "+parameter.Name, unsupportedPatterns(schema))
+				}
+
+				parameters[i] = parameter
+			}
+
+			responses := map[string]OperationResponse{}
+			for _, status := range sortedKeys(operation.Responses) {
+				response := operation.Responses[status]
+				if _, ok := response.Examples[jsonContentType]; !ok && response.Schema != nil {
+					examples := map[string]interface{}{jsonContentType: exampleValue(*response.Schema, "", random)}
+					report("the "+status+" response", unsupportedPatterns(*response.Schema))
+					for mediaType, example := range response.Examples {
+						examples[mediaType] = example
+					}
+
+					response.Examples = examples
+				}
+
+				responses[status] = response
+			}
+
+			operation.Parameters = parameters
+			operation.Responses = responses
+			methods[method] = operation
+		}
+	}
+
+	return
+}
+
+// exampleValue walks the schema, documented examples and enums win over the values guessed from the type,
+// format and name, which are then bound by the constraints of the schema
+func exampleValue(schema SchemaParameters, name string, random *rand.Rand) interface{} {
+	if schema.Example != nil {
+		return schema.Example
+	}
+
+	if len(schema.Enum) > 0 {
+		return schema.Enum[random.Intn(len(schema.Enum))]
+	}
+
+	switch schema.Type {
+	case "object":
+		//properties without an example are left out rather than sent as null
+		object := map[string]interface{}{}
+		for _, property := range sortedKeys(schema.Properties) {
+			if value := exampleValue(schema.Properties[property], property, random); value != nil {
+				object[property] = value
+			}
+		}
+
+		return object
+	case "array":
+		array := []interface{}{}
+		if schema.Items != nil {
+			for i, count := 0, 1+random.Intn(2); i < count; i++ {
+				if value := exampleValue(*schema.Items, singular(name), random); value != nil {
+					array = append(array, value)
+				}
+			}
+		}
+
+		return array
+	case "integer":
+		return int64(exampleNumber(schema, name, random))
+	case "number":
+		return exampleNumber(schema, name, random)
+	case "boolean":
+		return random.Intn(2) == 1
+	case "string":
+		if value, ok := exampleString(schema, name, random); ok {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func exampleNumber(schema SchemaParameters, name string, random *rand.Rand) (value float64) {
+	key := exampleKey(name)
+	switch {
+	case key == "age":
+		value = float64(18 + random.Intn(70))
+	case isIDName(name):
+		value = float64(1 + random.Intn(10000))
+	case containsAny(key, "price", "amount", "total", "cost", "balance"):
+		value = float64(100+random.Intn(100000)) / 100
+	case containsAny(key, "count", "quantity", "size", "limit"):
+		value = float64(1 + random.Intn(20))
+	case containsAny(key, "page", "offset"):
+		value = float64(random.Intn(10))
+	case key == "latitude" || key == "lat":
+		value = float64(random.Intn(18000)-9000) / 100
+	case key == "longitude" || key == "lng" || key == "lon":
+		value = float64(random.Intn(36000)-18000) / 100
+	case schema.Type == "integer":
+		value = float64(1 + random.Intn(1000))
+	default:
+		value = float64(random.Intn(100000)) / 100
+	}
+
+	if schema.Minimum != nil && value < *schema.Minimum {
+		value = *schema.Minimum
+	}
+
+	if schema.Maximum != nil && value > *schema.Maximum {
+		value = *schema.Maximum
+	}
+
+	if schema.Type == "integer" {
+		value = math.Trunc(value)
+	}
+
+	return
+}
+
+// exampleString guesses the value from the format and the name, the pattern wins when there is one
+// and no value is returned when it is too complex
+func exampleString(schema SchemaParameters, name string, random *rand.Rand) (value string, ok bool) {
+	if len(schema.Pattern) > 0 {
+		return examplePattern(schema.Pattern, random)
+	}
+
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(random.Intn(3*365*24*60)) * time.Minute)
+	key := exampleKey(name)
+	format := schema.Format
+	switch {
+	case len(format) > 0:
+	case strings.Contains(key, "email"):
+		format = "email"
+	case strings.HasSuffix(key, "uuid"):
+		format = "uuid"
+	case strings.HasSuffix(name, "At") || strings.HasSuffix(strings.ToLower(name), "_at") || containsAny(key, "time"):
+		format = "date-time"
+	case containsAny(key, "date", "birthday"):
+		format = "date"
+	case containsAny(key, "url", "uri", "link", "website", "avatar"):
+		format = "uri"
+	}
+
+	first := exampleFirstNames[random.Intn(len(exampleFirstNames))]
+	last := exampleLastNames[random.Intn(len(exampleLastNames))]
+	word := exampleWords[random.Intn(len(exampleWords))]
+	switch {
+	case format == "date-time":
+		value = date.Format(time.RFC3339)
+	case format == "date":
+		value = date.Format("2006-01-02")
+	case format == "uuid":
+		value = fmt.Sprintf("%08x-%04x-4%03x-8%03x-%012x", random.Uint32(), random.Intn(1<<16), random.Intn(1<<12), random.Intn(1<<12), random.Int63n(1<<48))
+	case format == "email":
+		value = strings.ToLower(first+"."+last) + "@example.com"
+	case format == "uri":
+		value = fmt.Sprintf("https://example.com/%s/%d", word, random.Intn(1000))
+	case format == "ipv4":
+		value = fmt.Sprintf("192.0.2.%d", 1+random.Intn(254))
+	case format == "password" || strings.Contains(key, "password"):
+		value = "correct-horse-battery-staple"
+	case isIDName(name):
+		value = fmt.Sprintf("%s_%d", word, 1000+random.Intn(9000))
+	case key == "firstname":
+		value = first
+	case key == "lastname" || key == "surname":
+		value = last
+	case key == "username" || key == "login" || key == "nickname":
+		value = strings.ToLower(first) + fmt.Sprint(random.Intn(100))
+	case key == "name" || key == "fullname":
+		value = first + " " + last
+	case containsAny(key, "phone", "mobile"):
+		value = fmt.Sprintf("+1 555 01%02d", random.Intn(100))
+	case key == "city":
+		value = exampleCities[random.Intn(len(exampleCities))]
+	case key == "country":
+		value = "PT"
+	case containsAny(key, "currency"):
+		value = "EUR"
+	case containsAny(key, "token", "secret", "key"):
+		value = fmt.Sprintf("%x", random.Int63())
+	case containsAny(key, "description", "summary", "comment", "message", "text", "body"):
+		value = fmt.Sprintf("The %s by the %s", word, exampleWords[random.Intn(len(exampleWords))])
+	case containsAny(key, "title"):
+		value = convertFromCamelCase(word) + " " + convertFromCamelCase(exampleWords[random.Intn(len(exampleWords))])
+	default:
+		value = word
+	}
+
+	if schema.MaxLength != nil && len(value) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+
+	for schema.MinLength != nil && len(value) < *schema.MinLength {
+		value += "x"
+	}
+
+	return value, true
+}
+
+// examplePattern generates a string matching a pattern made of literals, character classes, quantifiers,
+// groups and alternations, e.g. ^[0-9a-f]{24}$. Anything else, like word boundaries, is not supported
+// and the value is only returned when the regexp confirms the match.
+func examplePattern(pattern string, random *rand.Rand) (value string, ok bool) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return
+	}
+
+	tree, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return
+	}
+
+	var builder strings.Builder
+	if !writePattern(&builder, tree, random) || !regex.MatchString(builder.String()) {
+		return
+	}
+
+	return builder.String(), true
+}
+
+func writePattern(builder *strings.Builder, node *syntax.Regexp, random *rand.Rand) bool {
+	switch node.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+	case syntax.OpLiteral:
+		builder.WriteString(string(node.Rune))
+	case syntax.OpCharClass:
+		builder.WriteRune(exampleRune(node.Rune, random))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		builder.WriteString(exampleWords[random.Intn(len(exampleWords))][:1])
+	case syntax.OpCapture, syntax.OpConcat:
+		for _, sub := range node.Sub {
+			if !writePattern(builder, sub, random) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		return writePattern(builder, node.Sub[random.Intn(len(node.Sub))], random)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		//unbounded quantifiers repeat at most twice more than required
+		minimum, maximum := node.Min, node.Max
+		switch node.Op {
+		case syntax.OpStar:
+			minimum, maximum = 0, -1
+		case syntax.OpPlus:
+			minimum, maximum = 1, -1
+		case syntax.OpQuest:
+			minimum, maximum = 0, 1
+		}
+
+		if maximum < 0 {
+			maximum = minimum + 2
+		}
+
+		for count := minimum + random.Intn(maximum-minimum+1); count > 0; count-- {
+			if !writePattern(builder, node.Sub[0], random) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// exampleRune picks a printable ascii character of the class when it has one, its ranges are pairs of runes
+func exampleRune(ranges []rune, random *rand.Rand) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1] && r <= '~'; r++ {
+			if r >= '!' {
+				printable = append(printable, r)
+			}
+		}
+	}
+
+	if len(printable) == 0 {
+		return ranges[0]
+	}
+
+	return printable[random.Intn(len(printable))]
+}
+
+// unsupportedPatterns lists the patterns of the schema that examplePattern can't generate a value for
+func unsupportedPatterns(schema SchemaParameters) (patterns []string) {
+	if schema.Example != nil || len(schema.Enum) > 0 {
+		return
+	}
+
+	if schema.Type == "string" && len(schema.Pattern) > 0 {
+		if _, ok := examplePattern(schema.Pattern, rand.New(rand.NewSource(0))); !ok {
+			patterns = append(patterns, schema.Pattern)
+		}
+	}
+
+	for _, property := range sortedKeys(schema.Properties) {
+		patterns = append(patterns, unsupportedPatterns(schema.Properties[property])...)
+	}
+
+	if schema.Items != nil {
+		patterns = append(patterns, unsupportedPatterns(*schema.Items)...)
+	}
+
+	return
+}
+
+// exampleKey compares names in lower case without separators, createdAt and created_at are both createdat
+func exampleKey(name string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(name))
+}
+
+// isIDName matches id, userId, userID and user_id
+func isIDName(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "id" || strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID") || strings.HasSuffix(lower, "_id")
+}
+
+// singular names the items of an array, e.g. emails holds email values
+func singular(name string) string {
+	if strings.HasSuffix(name, "ies") {
+		return strings.TrimSuffix(name, "ies") + "y"
+	}
+
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return strings.TrimSuffix(name, "s")
+	}
+
+	return name
+}
+
+func containsAny(value string, parts ...string) bool {
+	for _, part := range parts {
+		if strings.Contains(value, part) {
+			return true
+		}
+	}
+
+	return false
+}
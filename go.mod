@@ -1,6 +1,6 @@
 module github.com/plicca/summerfish-swagger
 
-go 1.12
+go 1.16
 
 require (
 	github.com/go-kit/kit v0.10.0
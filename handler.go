@@ -1,41 +1,188 @@
 package summerfish
 
 import (
-	"io"
-	"io/ioutil"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
-type handler struct {
-	modTime time.Time
-	body    io.ReadSeeker
+const (
+	jsonContentType = "application/json"
+	yamlContentType = "application/yaml"
+)
+
+type specDocument struct {
+	contentType string
+	body        []byte
+	gzipped     []byte
+	etag        string
 }
 
-func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	http.ServeContent(w, r, "Swagger json spec", h.modTime, h.body)
+type specHandler struct {
+	modTime time.Time
+	json    specDocument
+	yaml    specDocument
 }
 
-// FileHandler returns an HTTP handler that serves the swagger.json file
-func fileHandler(swaggerPath string) (http.Handler, error) {
-	data, err := os.Open(swaggerPath)
+// SpecHandler returns an HTTP handler that serves the document from memory.
+// JSON is served by default, YAML when requested through the Accept header or with ?format=yaml.
+// The document must be built before, either with Build or one of the Generate/Marshal methods.
+func SpecHandler(s *SchemeHolder) (http.Handler, error) {
+	encodedJson, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	encodedYaml, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &specHandler{modTime: time.Now()}
+	h.json, err = newSpecDocument(jsonContentType, encodedJson)
 	if err != nil {
 		return nil, err
 	}
-	return &handler{modTime: time.Now(), body: data}, nil
+
+	h.yaml, err = newSpecDocument(yamlContentType, encodedYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
 }
 
-func updateIndexFile(path, route string) (err error) {
-	filePath := path + "/swaggerui/index.html"
-	input, err := ioutil.ReadFile(filePath)
+func newSpecDocument(contentType string, body []byte) (doc specDocument, err error) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	_, err = writer.Write(body)
+	if err != nil {
+		return
+	}
+
+	err = writer.Close()
 	if err != nil {
 		return
 	}
 
-	lines := strings.Split(string(input), "\n")
-	lines[41] = "url: \"" + route + "\","
-	output := strings.Join(lines, "\n")
-	return ioutil.WriteFile(filePath, []byte(output), 0644)
+	doc = specDocument{
+		contentType: contentType,
+		body:        body,
+		gzipped:     gzipped.Bytes(),
+		etag:        fmt.Sprintf("\"%x\"", sha256.Sum256(body)),
+	}
+	return
+}
+
+func (h *specHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	doc := h.json
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "yaml", "yml":
+		doc = h.yaml
+	case "json":
+	default:
+		if acceptsYaml(r.Header.Get("Accept")) {
+			doc = h.yaml
+		}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("Content-Type", doc.contentType)
+	body, etag := doc.body, doc.etag
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		body = doc.gzipped
+		etag = strings.TrimSuffix(etag, "\"") + "-gzip\""
+	}
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", h.modTime, bytes.NewReader(body))
+}
+
+// acceptsYaml prefers YAML when one of its media types weighs more than JSON, JSON wins ties
+func acceptsYaml(accept string) bool {
+	ranges := parseQualities(accept)
+	yamlQuality := 0.0
+	for _, mediaType := range []string{yamlContentType, "application/x-yaml", "text/yaml", "text/x-yaml"} {
+		if quality := mediaQuality(ranges, mediaType); quality > yamlQuality {
+			yamlQuality = quality
+		}
+	}
+
+	return yamlQuality > mediaQuality(ranges, jsonContentType)
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	quality, specific := 0.0, false
+	for _, coding := range parseQualities(acceptEncoding) {
+		switch {
+		case coding.value == "gzip" || coding.value == "x-gzip":
+			quality, specific = coding.quality, true
+		case coding.value == "*" && !specific:
+			quality = coding.quality
+		}
+	}
+
+	return quality > 0
+}
+
+type headerValue struct {
+	value   string
+	quality float64
+}
+
+// parseQualities splits a header such as "gzip;q=0.5, br" into its values and q weights, which default to 1
+func parseQualities(header string) (values []headerValue) {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if len(value) == 0 {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			pair := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(pair) == 2 && strings.EqualFold(strings.TrimSpace(pair[0]), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64); err == nil {
+					quality = parsed
+				}
+			}
+		}
+
+		values = append(values, headerValue{value: value, quality: quality})
+	}
+
+	return
+}
+
+// mediaQuality is the weight of the most specific media range matching mediaType, 0 when none does
+func mediaQuality(ranges []headerValue, mediaType string) (quality float64) {
+	specificity := -1
+	for _, mediaRange := range ranges {
+		level := -1
+		switch mediaRange.value {
+		case mediaType:
+			level = 2
+		case strings.SplitN(mediaType, "/", 2)[0] + "/*":
+			level = 1
+		case "*/*":
+			level = 0
+		}
+
+		if level > specificity {
+			quality, specificity = mediaRange.quality, level
+		}
+	}
+
+	return
 }
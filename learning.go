@@ -0,0 +1,500 @@
+package summerfish
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultLearningBodySize = 1 << 20
+
+// LearnedOperation holds the schemas inferred from the traffic of one operation, responses are keyed by status
+type LearnedOperation struct {
+	Request   *SchemaParameters           `json:"request,omitempty" yaml:"request,omitempty"`
+	Responses map[string]SchemaParameters `json:"responses,omitempty" yaml:"responses,omitempty"`
+}
+
+// LearnedSchemas maps "method template", e.g. "post /api/users/{id}", to what was learned for the operation
+type LearnedSchemas map[string]LearnedOperation
+
+// SchemaLearner samples the JSON bodies of live requests and responses and infers their schemas per route.
+// Types are merged across samples, properties missing from some samples are optional and strings
+// always matching a format (date-time, date, uuid, email, uri, ipv4) document it.
+type SchemaLearner struct {
+	SampleRate  float64
+	MaxBodySize int64
+	mutex       sync.Mutex
+	operations  map[string]*observedOperation
+	random      *rand.Rand
+}
+
+type observedOperation struct {
+	request   *observedSchema
+	responses map[string]*observedSchema
+}
+
+// observedSchema counts what was seen at one location of the bodies
+type observedSchema struct {
+	types      map[string]int
+	objects    int
+	properties map[string]*observedSchema
+	items      *observedSchema
+	strings    int
+	formats    map[string]int
+}
+
+type stringFormat struct {
+	name  string
+	match func(value string) bool
+}
+
+var (
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	ipv4Regex  = regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}$`)
+)
+
+// formats are listed from the most to the least specific, the first one matched by every sample is used
+var stringFormats = []stringFormat{
+	{"date-time", func(value string) bool {
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	}},
+	{"date", func(value string) bool {
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	}},
+	{"uuid", uuidRegex.MatchString},
+	{"email", emailRegex.MatchString},
+	{"uri", func(value string) bool {
+		parsed, err := url.ParseRequestURI(value)
+		return err == nil && len(parsed.Scheme) > 0 && len(parsed.Host) > 0
+	}},
+	{"ipv4", ipv4Regex.MatchString},
+}
+
+// NewSchemaLearner samples the given fraction of the requests, from 0 to 1
+func NewSchemaLearner(sampleRate float64) *SchemaLearner {
+	return &SchemaLearner{
+		SampleRate:  sampleRate,
+		MaxBodySize: defaultLearningBodySize,
+		operations:  map[string]*observedOperation{},
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Middleware records the bodies of the sampled requests routed by gorilla mux, use it with Router.Use
+func (l *SchemaLearner) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//upgraded connections, e.g. websockets, have no bodies to learn from
+		route := mux.CurrentRoute(r)
+		if route == nil || len(r.Header.Get("Upgrade")) > 0 || !l.sample() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var request []byte
+		if isJSONContent(r.Header.Get(contentTypeHeader)) && r.ContentLength >= 0 && r.ContentLength <= l.MaxBodySize {
+			request, _ = ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(request))
+		}
+
+		recorder := &teeResponseWriter{ResponseWriter: w, limit: l.MaxBodySize}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		var response []byte
+		if !recorder.truncated && isJSONContent(w.Header().Get(contentTypeHeader)) {
+			response = recorder.body.Bytes()
+		}
+
+		l.Observe(r.Method, normalizeTemplate(template), request, recorder.status, response)
+	})
+}
+
+func (l *SchemaLearner) sample() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.random.Float64() < l.SampleRate
+}
+
+// Observe records a request and its response, bodies which are empty or not JSON are ignored
+func (l *SchemaLearner) Observe(method, template string, request []byte, status int, response []byte) {
+	requestValue, hasRequest := decodeJSONBody(request)
+	responseValue, hasResponse := decodeJSONBody(response)
+	if !hasRequest && !hasResponse {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	key := strings.ToLower(method) + " " + template
+	operation, ok := l.operations[key]
+	if !ok {
+		operation = &observedOperation{responses: map[string]*observedSchema{}}
+		l.operations[key] = operation
+	}
+
+	if hasRequest {
+		if operation.request == nil {
+			operation.request = newObservedSchema()
+		}
+
+		operation.request.observe(requestValue)
+	}
+
+	if hasResponse {
+		code := strconv.Itoa(status)
+		if _, ok := operation.responses[code]; !ok {
+			operation.responses[code] = newObservedSchema()
+		}
+
+		operation.responses[code].observe(responseValue)
+	}
+}
+
+// Schemas infers the schemas from what was observed so far
+func (l *SchemaLearner) Schemas() LearnedSchemas {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	learned := LearnedSchemas{}
+	for key, operation := range l.operations {
+		var result LearnedOperation
+		if operation.request != nil {
+			schema := operation.request.schema()
+			result.Request = &schema
+		}
+
+		for status, observed := range operation.responses {
+			if result.Responses == nil {
+				result.Responses = map[string]SchemaParameters{}
+			}
+
+			result.Responses[status] = observed.schema()
+		}
+
+		learned[key] = result
+	}
+
+	return learned
+}
+
+// Save writes the learned schemas as json, the file can be passed to Config.LearnedSchemas or generate -learned
+func (l *SchemaLearner) Save(path string) (err error) {
+	encoded, err := json.MarshalIndent(l.Schemas(), "", "  ")
+	if err != nil {
+		return
+	}
+
+	return createSwaggerFile(path, encoded)
+}
+
+// LoadLearnedSchemas reads a file written by SchemaLearner.Save
+func LoadLearnedSchemas(path string) (learned LearnedSchemas, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = yaml.Unmarshal(content, &learned)
+	return
+}
+
+// Apply fills the body and response schemas of the document which static analysis left empty or free-form,
+// e.g. map[string]interface{} bodies, returning how many were learned. Inferred and described schemas are kept.
+// Called after ApplyOverlays, the schemas set by the overlays are kept as well.
+func (learned LearnedSchemas) Apply(s *SchemeHolder) (count int) {
+	for _, key := range sortedKeys(learned) {
+		split := strings.SplitN(key, " ", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		path := trimBasePath(split[1], s.BasePath)
+		operation, ok := s.Paths[path][split[0]]
+		if !ok {
+			continue
+		}
+
+		if request := learned[key].Request; request != nil {
+			parameters := make([]InputParameter, len(operation.Parameters))
+			for i, parameter := range operation.Parameters {
+				if parameter.QueryType == "body" {
+					var filled bool
+					parameter.Schema, filled = fillSchema(parameter.Schema, *request)
+					if filled {
+						count++
+					}
+				}
+
+				parameters[i] = parameter
+			}
+
+			operation.Parameters = parameters
+		}
+
+		responses := map[string]OperationResponse{}
+		for status, response := range operation.Responses {
+			if schema, ok := learned[key].Responses[status]; ok && len(schema.Type) > 0 {
+				if response.Schema == nil {
+					response.Schema = &schema
+					count++
+				} else if filled, ok := fillSchema(*response.Schema, schema); ok {
+					response.Schema = &filled
+					count++
+				}
+			}
+
+			responses[status] = response
+		}
+
+		operation.Responses = responses
+		s.Paths[path][split[0]] = operation
+	}
+
+	return
+}
+
+// fillSchema replaces the free-form parts of schema by the learned ones
+func fillSchema(schema, learned SchemaParameters) (result SchemaParameters, filled bool) {
+	result = schema
+	if len(learned.Type) == 0 {
+		return
+	}
+
+	if isFreeForm(schema) {
+		return learned, true
+	}
+
+	if schema.Items != nil && learned.Items != nil {
+		items, ok := fillSchema(*schema.Items, *learned.Items)
+		result.Items = &items
+		filled = ok
+	}
+
+	if len(schema.Properties) > 0 {
+		result.Properties = map[string]SchemaParameters{}
+		for name, property := range schema.Properties {
+			if learnedProperty, ok := learned.Properties[name]; ok {
+				var propertyFilled bool
+				property, propertyFilled = fillSchema(property, learnedProperty)
+				filled = filled || propertyFilled
+			}
+
+			result.Properties[name] = property
+		}
+	}
+
+	return
+}
+
+// isFreeForm is true for schemas without a type, objects without properties and arrays without items
+func isFreeForm(schema SchemaParameters) bool {
+	switch schema.Type {
+	case "", "interface{}":
+		return true
+	case "object":
+		return len(schema.Properties) == 0
+	case "array":
+		return schema.Items == nil
+	}
+
+	return false
+}
+
+func newObservedSchema() *observedSchema {
+	return &observedSchema{types: map[string]int{}, properties: map[string]*observedSchema{}, formats: map[string]int{}}
+}
+
+func (o *observedSchema) observe(value interface{}) {
+	switch typed := value.(type) {
+	case nil:
+		o.types["null"]++
+	case bool:
+		o.types["boolean"]++
+	case json.Number:
+		if _, err := typed.Int64(); err == nil {
+			o.types["integer"]++
+		} else {
+			o.types["number"]++
+		}
+	case string:
+		o.types["string"]++
+		o.strings++
+		for _, format := range stringFormats {
+			if format.match(typed) {
+				o.formats[format.name]++
+			}
+		}
+	case []interface{}:
+		o.types["array"]++
+		if o.items == nil {
+			o.items = newObservedSchema()
+		}
+
+		for _, item := range typed {
+			o.items.observe(item)
+		}
+	case map[string]interface{}:
+		o.types["object"]++
+		o.objects++
+		for name, property := range typed {
+			if _, ok := o.properties[name]; !ok {
+				o.properties[name] = newObservedSchema()
+			}
+
+			o.properties[name].observe(property)
+		}
+	}
+}
+
+// schema merges the samples, integers seen along decimals are numbers and otherwise the most seen type wins
+func (o *observedSchema) schema() (schema SchemaParameters) {
+	types := map[string]int{}
+	for name, count := range o.types {
+		if name == "integer" && o.types["number"] > 0 {
+			name = "number"
+		}
+
+		types[name] += count
+	}
+
+	best := 0
+	for _, name := range sortedKeys(types) {
+		if name != "null" && types[name] > best {
+			schema.Type, best = name, types[name]
+		}
+	}
+
+	switch schema.Type {
+	case "string":
+		for _, format := range stringFormats {
+			if o.formats[format.name] == o.strings {
+				schema.Format = format.name
+				break
+			}
+		}
+	case "array":
+		if o.items != nil {
+			if items := o.items.schema(); len(items.Type) > 0 {
+				schema.Items = &items
+			}
+		}
+	case "object":
+		schema.Properties = map[string]SchemaParameters{}
+		for _, name := range sortedKeys(o.properties) {
+			property := o.properties[name].schema()
+			if len(property.Type) == 0 {
+				continue
+			}
+
+			schema.Properties[name] = property
+			if o.properties[name].count() == o.objects {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+
+	return
+}
+
+func (o *observedSchema) count() (total int) {
+	for _, count := range o.types {
+		total += count
+	}
+
+	return
+}
+
+func decodeJSONBody(body []byte) (value interface{}, ok bool) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func isJSONContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasSuffix(mediaType, "json")
+}
+
+// teeResponseWriter copies the response body up to the limit while it is sent
+type teeResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (w *teeResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush streams the response, streamed responses are not learned from since they may never end
+func (w *teeResponseWriter) Flush() {
+	w.truncated = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, nothing written on it is recorded
+func (w *teeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+
+	w.truncated = true
+	return hijacker.Hijack()
+}
+
+func (w *teeResponseWriter) Write(content []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if int64(w.body.Len()+len(content)) > w.limit {
+		w.truncated = true
+	} else if !w.truncated {
+		w.body.Write(content)
+	}
+
+	return w.ResponseWriter.Write(content)
+}
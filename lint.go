@@ -0,0 +1,385 @@
+package summerfish
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// LintRule checks the generated document, reporting each issue through the report function
+type LintRule struct {
+	Name        string
+	Description string
+	Severity    string
+	Check       func(s *SchemeHolder, report func(method, path, message string))
+}
+
+// LintConfig disables rules or overrides their severity by rule name
+type LintConfig struct {
+	Disabled   []string          `json:"disabled" yaml:"disabled"`
+	Severities map[string]string `json:"severities" yaml:"severities"`
+}
+
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Method   string `json:"method,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+type LintIssues []LintIssue
+
+var severityOrder = map[string]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// LintRules are the built-in rules run by Lint
+var LintRules = []LintRule{
+	{"duplicate-operation-id", "operationIds must be unique in the document", SeverityError, checkDuplicateOperationIDs},
+	{"empty-type", "parameters and schema properties must have a type, empty ones come from unresolved structs", SeverityWarning, checkEmptyTypes},
+	{"path-parameters", "path parameters must match the variables of the path template", SeverityError, checkPathParameters},
+	{"body-without-payload-method", "GET, HEAD and DELETE operations should not have a body", SeverityWarning, checkBodyMethods},
+	{"duplicate-parameter", "parameters must be unique by name and location", SeverityError, checkDuplicateParameters},
+	{"body-and-form-data", "an operation can't have both body and formData parameters", SeverityError, checkBodyAndFormData},
+	{"success-response", "operations should document a 2xx response", SeverityWarning, checkSuccessResponse},
+	{"operation-summary", "operations should have a summary", SeverityInfo, checkSummary},
+}
+
+// Lint runs the enabled rules over the document, issues are sorted by severity and location.
+// Severity overrides must be error, warning or info.
+func Lint(s *SchemeHolder, config LintConfig) (issues LintIssues, err error) {
+	for _, name := range sortedKeys(config.Severities) {
+		err = checkSeverity(config.Severities[name])
+		if err != nil {
+			return nil, fmt.Errorf("severity of %s: %v", name, err)
+		}
+	}
+
+	for _, rule := range LintRules {
+		if containsString(config.Disabled, rule.Name) {
+			continue
+		}
+
+		severity := rule.Severity
+		if override, ok := config.Severities[rule.Name]; ok {
+			severity = override
+		}
+
+		rule.Check(s, func(method, path, message string) {
+			issues = append(issues, LintIssue{
+				Rule:     rule.Name,
+				Severity: severity,
+				Method:   strings.ToUpper(method),
+				Path:     path,
+				Message:  message,
+			})
+		})
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Severity != issues[j].Severity {
+			return severityOrder[issues[i].Severity] < severityOrder[issues[j].Severity]
+		}
+
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+
+		return issues[i].Method < issues[j].Method
+	})
+
+	return
+}
+
+// forEachOperation visits the operations sorted by path and method so that reports are stable
+func forEachOperation(s *SchemeHolder, visit func(method, path string, operation Operation)) {
+	for _, path := range sortedKeys(s.Paths) {
+		for _, method := range sortedKeys(s.Paths[path]) {
+			visit(method, path, s.Paths[path][method])
+		}
+	}
+}
+
+func checkDuplicateOperationIDs(s *SchemeHolder, report func(method, path, message string)) {
+	seen := map[string]string{}
+	forEachOperation(s, func(method, path string, operation Operation) {
+		if len(operation.ID) == 0 {
+			report(method, path, "operation has no operationId")
+			return
+		}
+
+		if previous, ok := seen[operation.ID]; ok {
+			report(method, path, fmt.Sprintf("operationId %s is already used by %s", operation.ID, previous))
+			return
+		}
+
+		seen[operation.ID] = strings.ToUpper(method) + " " + path
+	})
+}
+
+func checkEmptyTypes(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		for _, parameter := range operation.Parameters {
+			if parameter.QueryType == "body" {
+				checkSchemaTypes(parameter.Schema, "body "+parameter.Name, func(message string) { report(method, path, message) })
+			} else if len(parameter.Type) == 0 {
+				report(method, path, fmt.Sprintf("parameter %s in %s has no type", parameter.Name, parameter.QueryType))
+			}
+		}
+
+		for _, status := range sortedKeys(operation.Responses) {
+			if schema := operation.Responses[status].Schema; schema != nil {
+				checkSchemaTypes(*schema, "response "+status, func(message string) { report(method, path, message) })
+			}
+		}
+	})
+}
+
+func checkSchemaTypes(schema SchemaParameters, location string, report func(message string)) {
+	if len(schema.Type) == 0 {
+		report(location + " has no type")
+	}
+
+	if schema.Items != nil {
+		checkSchemaTypes(*schema.Items, location+"[]", report)
+	}
+
+	for _, name := range sortedKeys(schema.Properties) {
+		checkSchemaTypes(schema.Properties[name], location+"."+name, report)
+	}
+}
+
+func checkPathParameters(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		declared := map[string]bool{}
+		for _, parameter := range operation.Parameters {
+			if parameter.QueryType != "path" {
+				continue
+			}
+
+			declared[parameter.Name] = true
+			if !strings.Contains(path, "{"+parameter.Name+"}") {
+				report(method, path, fmt.Sprintf("path parameter %s is not in the path template", parameter.Name))
+			}
+		}
+
+		for _, variable := range parseTemplateVariables(path) {
+			if !declared[variable.Name] {
+				report(method, path, fmt.Sprintf("path variable %s has no parameter", variable.Name))
+			}
+		}
+	})
+}
+
+func checkBodyMethods(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		if method != "get" && method != "head" && method != "delete" {
+			return
+		}
+
+		for _, parameter := range operation.Parameters {
+			if parameter.QueryType == "body" || parameter.QueryType == "formData" {
+				report(method, path, fmt.Sprintf("%s operation has a %s parameter %s", strings.ToUpper(method), parameter.QueryType, parameter.Name))
+			}
+		}
+	})
+}
+
+func checkDuplicateParameters(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		seen := map[string]bool{}
+		for _, parameter := range operation.Parameters {
+			key := parameter.QueryType + " " + parameter.Name
+			if seen[key] {
+				report(method, path, fmt.Sprintf("parameter %s in %s is declared more than once", parameter.Name, parameter.QueryType))
+			}
+
+			seen[key] = true
+		}
+	})
+}
+
+func checkBodyAndFormData(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		hasBody, hasFormData := false, false
+		for _, parameter := range operation.Parameters {
+			hasBody = hasBody || parameter.QueryType == "body"
+			hasFormData = hasFormData || parameter.QueryType == "formData"
+		}
+
+		if hasBody && hasFormData {
+			report(method, path, "operation has both body and formData parameters")
+		}
+	})
+}
+
+func checkSuccessResponse(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		for status := range operation.Responses {
+			if strings.HasPrefix(status, "2") || status == "default" {
+				return
+			}
+		}
+
+		report(method, path, "operation has no successful response")
+	})
+}
+
+func checkSummary(s *SchemeHolder, report func(method, path, message string)) {
+	forEachOperation(s, func(method, path string, operation Operation) {
+		if len(strings.TrimSpace(operation.Summary)) == 0 {
+			report(method, path, "operation has no summary")
+		}
+	})
+}
+
+// Count returns how many issues have the given severity
+func (issues LintIssues) Count(severity string) (count int) {
+	for _, issue := range issues {
+		if issue.Severity == severity {
+			count++
+		}
+	}
+
+	return
+}
+
+// HasSeverity reports whether any issue is at least as severe as the given one, which must be error, warning or info
+func (issues LintIssues) HasSeverity(severity string) (found bool, err error) {
+	err = checkSeverity(severity)
+	if err != nil {
+		return
+	}
+
+	for _, issue := range issues {
+		if severityOrder[issue.Severity] <= severityOrder[severity] {
+			return true, nil
+		}
+	}
+
+	return
+}
+
+func checkSeverity(severity string) error {
+	if _, ok := severityOrder[severity]; !ok {
+		return fmt.Errorf("unknown severity %q, expected %s, %s or %s", severity, SeverityError, SeverityWarning, SeverityInfo)
+	}
+
+	return nil
+}
+
+func (issue LintIssue) String() string {
+	location := issue.Path
+	if len(issue.Method) > 0 {
+		location = issue.Method + " " + issue.Path
+	}
+
+	return fmt.Sprintf("%s\t%s\t%s: %s", issue.Severity, issue.Rule, location, issue.Message)
+}
+
+// Text renders the issues one per line followed by a summary
+func (issues LintIssues) Text() string {
+	var builder strings.Builder
+	for _, issue := range issues {
+		builder.WriteString(issue.String() + "\n")
+	}
+
+	builder.WriteString(fmt.Sprintf("%d errors, %d warnings, %d infos\n",
+		issues.Count(SeverityError), issues.Count(SeverityWarning), issues.Count(SeverityInfo)))
+	return builder.String()
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+// SARIF renders the issues as a SARIF 2.1.0 log pointing to the spec file, e.g. for code scanning uploads
+func (issues LintIssues) SARIF(specURI string) ([]byte, error) {
+	driver := sarifDriver{Name: "summerfish", InformationURI: "https://github.com/plicca/summerfish-swagger"}
+	for _, rule := range LintRules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: rule.Name, ShortDescription: sarifMessage{Text: rule.Description}})
+	}
+
+	//results must be an empty array instead of null when there are no issues
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, issue := range issues {
+		level := issue.Severity
+		if level == SeverityInfo {
+			level = "note"
+		}
+
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: specURI}}}
+		if len(issue.Path) > 0 {
+			location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: strings.TrimSpace(issue.Method + " " + issue.Path)}}
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    issue.Rule,
+			Level:     level,
+			Message:   sarifMessage{Text: issue.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
@@ -0,0 +1,133 @@
+package summerfish
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+)
+
+// MockOptions configures MockHandler.
+// Responses are generated from a random source seeded with Seed and the request, so the same request always gets
+// the same response. Latency and ErrorRate apply to every route, Routes overrides them by "method path", e.g. "get /users/{id}".
+type MockOptions struct {
+	Seed        int64                `json:"seed" yaml:"seed"`
+	Latency     time.Duration        `json:"latency" yaml:"latency"`
+	ErrorRate   float64              `json:"errorRate" yaml:"errorRate"`
+	ErrorStatus int                  `json:"errorStatus" yaml:"errorStatus"`
+	Routes      map[string]MockRoute `json:"routes" yaml:"routes"`
+}
+
+// MockRoute injects latency and errors in one route, ErrorStatus defaults to 500
+type MockRoute struct {
+	Latency     time.Duration `json:"latency" yaml:"latency"`
+	ErrorRate   float64       `json:"errorRate" yaml:"errorRate"`
+	ErrorStatus int           `json:"errorStatus" yaml:"errorStatus"`
+}
+
+// LoadMockOptions reads yaml or json mock options, latencies are durations such as "150ms"
+func LoadMockOptions(path string) (options MockOptions, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = yaml.Unmarshal(content, &options)
+	return
+}
+
+// MockHandler serves every operation of the document, validating the requests with ValidationMiddleware
+// and answering the first documented success response with its example or one generated from its schema, see Example
+func MockHandler(s *SchemeHolder, options MockOptions) http.Handler {
+	router := mux.NewRouter()
+	prefix := strings.TrimSuffix(s.BasePath, "/")
+	forEachOperation(s, func(method, path string, operation Operation) {
+		route := options.route(method, path)
+		status, response := mockResponse(operation)
+		key := method + " " + path
+		router.HandleFunc(prefix+path, func(w http.ResponseWriter, r *http.Request) {
+			random := rand.New(rand.NewSource(options.Seed ^ requestSeed(r)))
+			if route.Latency > 0 {
+				select {
+				case <-time.After(route.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if route.ErrorRate > 0 && random.Float64() < route.ErrorRate {
+				writeProblem(w, Problem{
+					Type:     "about:blank",
+					Title:    http.StatusText(route.ErrorStatus),
+					Status:   route.ErrorStatus,
+					Detail:   fmt.Sprintf("error injected in %s", key),
+					Instance: r.URL.Path,
+				})
+				return
+			}
+
+			body, ok := response.Examples[jsonContentType]
+			if !ok && response.Schema != nil {
+				body, ok = exampleValue(*response.Schema, "", random), true
+			}
+
+			if !ok {
+				w.WriteHeader(status)
+				return
+			}
+
+			w.Header().Set(contentTypeHeader, jsonContentType)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(normalizeYaml(body))
+		}).Methods(strings.ToUpper(method))
+	})
+
+	router.Use(ValidationMiddleware(s, ValidationOptions{}))
+	return router
+}
+
+func (options MockOptions) route(method, path string) MockRoute {
+	route := MockRoute{Latency: options.Latency, ErrorRate: options.ErrorRate, ErrorStatus: options.ErrorStatus}
+	if override, ok := options.Routes[method+" "+path]; ok {
+		route = override
+	}
+
+	if route.ErrorStatus == 0 {
+		route.ErrorStatus = http.StatusInternalServerError
+	}
+
+	return route
+}
+
+// mockResponse picks the lowest documented 2xx response, then the default one
+func mockResponse(operation Operation) (status int, response OperationResponse) {
+	var statuses []string
+	for code := range operation.Responses {
+		if strings.HasPrefix(code, "2") {
+			statuses = append(statuses, code)
+		}
+	}
+
+	sort.Strings(statuses)
+	if len(statuses) > 0 {
+		status, _ = strconv.Atoi(statuses[0])
+		return status, operation.Responses[statuses[0]]
+	}
+
+	return http.StatusOK, operation.Responses["default"]
+}
+
+func requestSeed(r *http.Request) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	return int64(hash.Sum64())
+}
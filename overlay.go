@@ -0,0 +1,515 @@
+package summerfish
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DiagnosticOverlay is the kind of the warnings raised while applying overlays
+const DiagnosticOverlay = "overlay"
+
+// Overlay holds hand-written additions to the generated document.
+// Documents with an `overlay` version follow the OpenAPI Overlay specification, where each action
+// updates or removes the nodes selected by its JSONPath target. Any other document is deep-merged,
+// top level keys starting with "/" are JSON Pointers into the generated document.
+type Overlay struct {
+	Name     string
+	document map[string]interface{}
+}
+
+type overlayAction struct {
+	Target string      `yaml:"target"`
+	Update interface{} `yaml:"update"`
+	Remove bool        `yaml:"remove"`
+}
+
+// removedNode marks array elements removed by an action until the arrays are compacted
+type removedNode struct{}
+
+// documentExtension is a node added by the overlays which the fields of SchemeHolder can't hold,
+// e.g. an x- extension of an operation, located by the keys and indexes leading to it
+type documentExtension struct {
+	path  []string
+	value interface{}
+}
+
+type jsonPathMatch struct {
+	value interface{}
+	set   func(value interface{})
+}
+
+var (
+	jsonPathSegmentRegex = regexp.MustCompile(`^(\.\*|\.[^.\[]+|\[\*\]|\['[^']*'\]|\["[^"]*"\]|\[\d+\])`)
+	httpMethods          = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+)
+
+// LoadOverlay reads a json or yaml overlay document
+func LoadOverlay(path string) (overlay Overlay, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	return ParseOverlay(path, content)
+}
+
+// ParseOverlay parses a json or yaml overlay document, the name is used in the warnings
+func ParseOverlay(name string, content []byte) (overlay Overlay, err error) {
+	var document interface{}
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return
+	}
+
+	overlay.Name = name
+	overlay.document, _ = normalizeYaml(document).(map[string]interface{})
+	if overlay.document == nil {
+		err = fmt.Errorf("overlay %s is not an object", name)
+	}
+
+	return
+}
+
+// ApplyOverlays merges the overlays in order on top of the built document and decodes the result back into its fields.
+// Targets that match nothing, e.g. operations that no longer exist, are skipped and reported as warnings,
+// as are the paths and operations added by the overlays since they aren't served by the router.
+// The fields can be changed afterwards, what they can't hold is kept and merged in when the document is marshaled.
+// Building the document again discards the overlays.
+func (s *SchemeHolder) ApplyOverlays(overlays ...Overlay) (diagnostics Diagnostics, err error) {
+	document, err := s.encodeDocument()
+	if err != nil {
+		return
+	}
+
+	for _, overlay := range overlays {
+		var warnings []string
+		existing := documentOperations(document)
+		if _, ok := overlay.document["overlay"]; ok {
+			warnings, err = applyOverlayActions(document, overlay)
+			if err != nil {
+				return
+			}
+		} else {
+			warnings = mergeOverlay(document, overlay)
+		}
+
+		for _, operation := range documentOperations(document) {
+			if !containsString(existing, operation) {
+				warnings = append(warnings, fmt.Sprintf("%s is added by the overlay and not served by the router", operation))
+			}
+		}
+
+		for _, warning := range warnings {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Kind: DiagnosticOverlay, File: overlay.Name, Reason: warning})
+		}
+	}
+
+	//the fields are decoded again so that the code reading them sees the overlays
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return
+	}
+
+	var decoded plainSchemeHolder
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		err = fmt.Errorf("overlays produce an invalid document: %s", err)
+		return
+	}
+
+	generated := s.generated
+	if generated == nil {
+		previous := *s
+		generated = &previous
+	}
+
+	registry := s.Overrides
+	*s = SchemeHolder(decoded)
+	s.Overrides, s.generated = registry, generated
+
+	//only the nodes lost by decoding are kept aside, the fields are the source of everything else
+	fields, err := s.encodeDocument()
+	if err != nil {
+		return
+	}
+
+	collectExtensions(document, fields, nil, &s.extensions)
+	return
+}
+
+// encodeDocument encodes the fields into generic maps and merges the extensions of the overlays back in
+func (s SchemeHolder) encodeDocument() (document map[string]interface{}, err error) {
+	encoded, err := json.Marshal(plainSchemeHolder(s))
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(encoded, &document)
+	if err != nil {
+		return
+	}
+
+	for _, extension := range s.extensions {
+		restoreExtension(document, extension)
+	}
+
+	return
+}
+
+// collectExtensions lists the nodes of the document missing from the encoded fields
+func collectExtensions(document, fields interface{}, path []string, extensions *[]documentExtension) {
+	switch d := document.(type) {
+	case map[string]interface{}:
+		f, _ := fields.(map[string]interface{})
+		for _, key := range sortedKeys(d) {
+			location := append(path[:len(path):len(path)], key)
+			if value, ok := f[key]; ok {
+				collectExtensions(d[key], value, location, extensions)
+			} else {
+				*extensions = append(*extensions, documentExtension{path: location, value: d[key]})
+			}
+		}
+	case []interface{}:
+		f, _ := fields.([]interface{})
+		for i := range d {
+			if i < len(f) {
+				collectExtensions(d[i], f[i], append(path[:len(path):len(path)], strconv.Itoa(i)), extensions)
+			}
+		}
+	}
+}
+
+// restoreExtension sets the extension unless the fields no longer have its parent or already hold its key,
+// e.g. the extensions of an operation removed after the overlays were applied are dropped with it
+func restoreExtension(document map[string]interface{}, extension documentExtension) {
+	var node interface{} = document
+	for _, key := range extension.path[:len(extension.path)-1] {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			node = n[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index >= len(n) {
+				return
+			}
+
+			node = n[index]
+		default:
+			return
+		}
+	}
+
+	last := extension.path[len(extension.path)-1]
+	if parent, ok := node.(map[string]interface{}); ok {
+		if _, exists := parent[last]; !exists {
+			parent[last] = extension.value
+		}
+	}
+}
+
+// documentOperations lists the paths and the operations of the encoded document, e.g. "path /users" and "operation GET /users"
+func documentOperations(document map[string]interface{}) (operations []string) {
+	paths, _ := document["paths"].(map[string]interface{})
+	for _, path := range sortedKeys(paths) {
+		operations = append(operations, "path "+path)
+		methods, _ := paths[path].(map[string]interface{})
+		for _, method := range sortedKeys(methods) {
+			if containsString(httpMethods, method) {
+				operations = append(operations, fmt.Sprintf("operation %s %s", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	return
+}
+
+func applyOverlayActions(document map[string]interface{}, overlay Overlay) (warnings []string, err error) {
+	encoded, err := yaml.Marshal(overlay.document["actions"])
+	if err != nil {
+		return
+	}
+
+	var actions []overlayAction
+	err = yaml.Unmarshal(encoded, &actions)
+	if err != nil {
+		return
+	}
+
+	for i, action := range actions {
+		var matches []jsonPathMatch
+		matches, err = resolveJSONPath(document, action.Target)
+		if err != nil {
+			err = fmt.Errorf("overlay %s action %d: %s", overlay.Name, i, err)
+			return
+		}
+
+		if len(matches) == 0 {
+			warnings = append(warnings, fmt.Sprintf("target %s of action %d matches nothing", action.Target, i))
+			continue
+		}
+
+		for _, match := range matches {
+			if action.Remove {
+				match.set(removedNode{})
+			} else if action.Update != nil {
+				match.set(mergeNodes(match.value, normalizeYaml(action.Update), true))
+			}
+		}
+
+		if action.Remove {
+			compactNode(document)
+		}
+	}
+
+	return
+}
+
+// mergeOverlay deep-merges a plain document, paths and operations must already exist in the generated document
+func mergeOverlay(document map[string]interface{}, overlay Overlay) (warnings []string) {
+	for _, key := range sortedKeys(overlay.document) {
+		value := overlay.document[key]
+		switch {
+		case strings.HasPrefix(key, "/"):
+			if !setJSONPointer(document, key, value) {
+				warnings = append(warnings, fmt.Sprintf("pointer %s references a location that does not exist", key))
+			}
+		case key == "paths":
+			overlayPaths, _ := value.(map[string]interface{})
+			paths, _ := document["paths"].(map[string]interface{})
+			for _, path := range sortedKeys(overlayPaths) {
+				methods, ok := paths[path].(map[string]interface{})
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("path %s does not exist", path))
+					continue
+				}
+
+				overlayMethods, _ := overlayPaths[path].(map[string]interface{})
+				for _, method := range sortedKeys(overlayMethods) {
+					if _, ok := methods[method]; !ok && containsString(httpMethods, method) {
+						warnings = append(warnings, fmt.Sprintf("operation %s %s does not exist", strings.ToUpper(method), path))
+						continue
+					}
+
+					methods[method] = mergeNodes(methods[method], overlayMethods[method], false)
+				}
+			}
+		default:
+			document[key] = mergeNodes(document[key], value, false)
+		}
+	}
+
+	return
+}
+
+// mergeNodes merges objects recursively, arrays are appended to in overlay actions and replaced otherwise
+func mergeNodes(target, update interface{}, appendArrays bool) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		u, ok := update.(map[string]interface{})
+		if !ok {
+			return update
+		}
+
+		for key, value := range u {
+			if existing, ok := t[key]; ok {
+				t[key] = mergeNodes(existing, value, appendArrays)
+			} else {
+				t[key] = value
+			}
+		}
+
+		return t
+	case []interface{}:
+		if !appendArrays {
+			return update
+		}
+
+		if u, ok := update.([]interface{}); ok {
+			return append(t, u...)
+		}
+
+		return append(t, update)
+	}
+
+	return update
+}
+
+func compactNode(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if _, ok := value.(removedNode); ok {
+				delete(n, key)
+			} else {
+				n[key] = compactNode(value)
+			}
+		}
+	case []interface{}:
+		compacted := []interface{}{}
+		for _, value := range n {
+			if _, ok := value.(removedNode); !ok {
+				compacted = append(compacted, compactNode(value))
+			}
+		}
+
+		return compacted
+	}
+
+	return node
+}
+
+// resolveJSONPath supports the subset of JSONPath used by overlays: child names, wildcards and array indexes
+func resolveJSONPath(document map[string]interface{}, target string) (matches []jsonPathMatch, err error) {
+	if !strings.HasPrefix(target, "$") {
+		err = fmt.Errorf("target %s must start with $", target)
+		return
+	}
+
+	var segments []string
+	for rest := target[1:]; len(rest) > 0; {
+		segment := jsonPathSegmentRegex.FindString(rest)
+		if len(segment) == 0 {
+			err = fmt.Errorf("unsupported JSONPath expression %s", rest)
+			return
+		}
+
+		rest = rest[len(segment):]
+		segment = strings.TrimPrefix(strings.TrimPrefix(segment, "."), "[")
+		segment = strings.Trim(strings.TrimSuffix(segment, "]"), `'"`)
+		segments = append(segments, segment)
+	}
+
+	matches = []jsonPathMatch{{value: document, set: func(interface{}) {}}}
+	for _, segment := range segments {
+		var next []jsonPathMatch
+		for _, match := range matches {
+			next = append(next, selectChildren(match.value, segment)...)
+		}
+
+		matches = next
+	}
+
+	return
+}
+
+func selectChildren(node interface{}, segment string) (matches []jsonPathMatch) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := []string{segment}
+		if segment == "*" {
+			keys = sortedKeys(n)
+		}
+
+		for _, key := range keys {
+			key := key
+			if value, ok := n[key]; ok {
+				matches = append(matches, jsonPathMatch{value: value, set: func(value interface{}) { n[key] = value }})
+			}
+		}
+	case []interface{}:
+		for i := range n {
+			i := i
+			if segment == "*" || segment == strconv.Itoa(i) {
+				matches = append(matches, jsonPathMatch{value: n[i], set: func(value interface{}) { n[i] = value }})
+			}
+		}
+	}
+
+	return
+}
+
+// setJSONPointer merges the value at the pointer, the parent of the location must exist
+func setJSONPointer(document map[string]interface{}, pointer string, value interface{}) bool {
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(tokens[i], "~1", "/", -1), "~0", "~", -1)
+	}
+
+	var node interface{} = document
+	for _, token := range tokens[:len(tokens)-1] {
+		matches := selectChildren(node, token)
+		if len(matches) != 1 || token == "*" {
+			return false
+		}
+
+		node = matches[0].value
+	}
+
+	last := tokens[len(tokens)-1]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[last] = mergeNodes(n[last], value, false)
+		return true
+	case []interface{}:
+		index, err := strconv.Atoi(last)
+		if err != nil || index >= len(n) {
+			return false
+		}
+
+		n[index] = mergeNodes(n[index], value, false)
+		return true
+	}
+
+	return false
+}
+
+// normalizeYaml copies the maps decoded by yaml, whose keys are interface{}, into the ones decoded by encoding/json,
+// the node itself is left untouched since it may be shared, e.g. the examples served by the mocks
+func normalizeYaml(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, value := range n {
+			result[fmt.Sprint(key)] = normalizeYaml(value)
+		}
+
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, value := range n {
+			result[key] = normalizeYaml(value)
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(n))
+		for i := range n {
+			result[i] = normalizeYaml(n[i])
+		}
+
+		return result
+	}
+
+	return node
+}
+
+type plainSchemeHolder SchemeHolder
+
+// MarshalJSON encodes the fields with what the applied overlays added and the fields can't hold
+func (s SchemeHolder) MarshalJSON() ([]byte, error) {
+	if len(s.extensions) == 0 {
+		return json.Marshal(plainSchemeHolder(s))
+	}
+
+	document, err := s.encodeDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
+}
+
+// MarshalYAML encodes the fields with what the applied overlays added and the fields can't hold
+func (s SchemeHolder) MarshalYAML() (interface{}, error) {
+	if len(s.extensions) == 0 {
+		return plainSchemeHolder(s), nil
+	}
+
+	return s.encodeDocument()
+}
@@ -0,0 +1,490 @@
+package summerfish
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// OperationOverride replaces what was inferred for the operations of a handler or of a route.
+// Overrides are registered in an Overrides registry by Describe, DescribeRoute and DescribeMiddleware and applied by Build.
+type OperationOverride struct {
+	registry    *Overrides
+	handler     string
+	middleware  string
+	method      string
+	route       string
+	summary     string
+	description string
+	operationID string
+	tags        []string
+	responses   map[string]OperationResponse
+	types       map[string]NameType
+	parameters  []InputParameter
+	deprecated  bool
+	security    []SecurityRequirement
+}
+
+// Overrides is a registry of operation and schema overrides. The package-level Describe functions and OverrideSchema
+// register in DefaultOverrides, a registry set in Config.Overrides or SchemeHolder.Overrides keeps the overrides
+// of one spec apart from the others, e.g. for the services or the tests sharing a process.
+type Overrides struct {
+	mutex      sync.Mutex
+	operations []*OperationOverride
+	schemas    map[string]SchemaParameters
+}
+
+// DefaultOverrides is the registry of the package-level functions, used wherever no registry is set
+var DefaultOverrides = NewOverrides()
+
+// NewOverrides returns an empty registry, time.Time is documented as a string like encoding/json encodes it
+func NewOverrides() *Overrides {
+	return &Overrides{schemas: map[string]SchemaParameters{"time.Time": {Type: "string"}}}
+}
+
+// Describe overrides the operations served by the handler in DefaultOverrides, see Overrides.Describe
+func Describe(handler interface{}) *OperationOverride {
+	return DefaultOverrides.Describe(handler)
+}
+
+// DescribeMiddleware describes a middleware in DefaultOverrides, see Overrides.DescribeMiddleware
+func DescribeMiddleware(middleware mux.MiddlewareFunc) *OperationOverride {
+	return DefaultOverrides.DescribeMiddleware(middleware)
+}
+
+// DescribeRoute overrides an operation in DefaultOverrides, see Overrides.DescribeRoute
+func DescribeRoute(method, route string) *OperationOverride {
+	return DefaultOverrides.DescribeRoute(method, route)
+}
+
+// OverrideSchema documents a Go type in DefaultOverrides, see Overrides.OverrideSchema
+func OverrideSchema(value interface{}, schema SchemaParameters) {
+	DefaultOverrides.OverrideSchema(value, schema)
+}
+
+// ClearOverrides removes the operation and schema overrides registered in DefaultOverrides
+func ClearOverrides() {
+	DefaultOverrides.Clear()
+}
+
+// Describe overrides the operations served by the handler, e.g. an http.HandlerFunc or a go-kit server
+func (o *Overrides) Describe(handler interface{}) *OperationOverride {
+	if function, ok := handler.(func(http.ResponseWriter, *http.Request)); ok {
+		handler = http.HandlerFunc(function)
+	}
+
+	override := &OperationOverride{}
+	if h, ok := handler.(http.Handler); ok {
+		namePath, _ := processHandler(h)
+		override.handler = namePath.RelativePath
+	}
+
+	return o.register(override)
+}
+
+// DescribeMiddleware declares what a middleware registered with Router.Use adds to every operation beneath it,
+// e.g. a required header, security or the 401 and 429 responses. Handler and route overrides are applied after it.
+func (o *Overrides) DescribeMiddleware(middleware mux.MiddlewareFunc) *OperationOverride {
+	return o.register(&OperationOverride{middleware: getFunctionName(reflect.ValueOf(middleware))})
+}
+
+// DescribeRoute overrides the operation registered with the method and path template, an empty method matches all of them
+func (o *Overrides) DescribeRoute(method, route string) *OperationOverride {
+	return o.register(&OperationOverride{method: strings.ToUpper(method), route: normalizeTemplate(route)})
+}
+
+// OverrideSchema documents the Go type of value with the schema wherever it is found, e.g. in bodies or responses
+func (o *Overrides) OverrideSchema(value interface{}, schema SchemaParameters) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.schemas[reflect.TypeOf(value).String()] = schema
+}
+
+// Clear removes the registered operation and schema overrides
+func (o *Overrides) Clear() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.operations = nil
+	o.schemas = map[string]SchemaParameters{"time.Time": {Type: "string"}}
+}
+
+func (o *Overrides) register(override *OperationOverride) *OperationOverride {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	override.registry = o
+	o.operations = append(o.operations, override)
+	return override
+}
+
+// orDefault lets the code reading the registries take nil for DefaultOverrides
+func (o *Overrides) orDefault() *Overrides {
+	if o == nil {
+		return DefaultOverrides
+	}
+
+	return o
+}
+
+func (o *OperationOverride) Summary(summary string) *OperationOverride {
+	o.summary = summary
+	return o
+}
+
+func (o *OperationOverride) Description(description string) *OperationOverride {
+	o.description = description
+	return o
+}
+
+func (o *OperationOverride) OperationID(id string) *OperationOverride {
+	o.operationID = id
+	return o
+}
+
+func (o *OperationOverride) Tags(tags ...string) *OperationOverride {
+	o.tags = tags
+	return o
+}
+
+func (o *OperationOverride) Deprecated() *OperationOverride {
+	o.deprecated = true
+	return o
+}
+
+// Security requires the security definition with the scopes, replacing the inferred requirements
+func (o *OperationOverride) Security(name string, scopes ...string) *OperationOverride {
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	o.security = append(o.security, SecurityRequirement{name: scopes})
+	return o
+}
+
+// Header adds a required header parameter
+func (o *OperationOverride) Header(name, description string) *OperationOverride {
+	return o.Param(InputParameter{Name: http.CanonicalHeaderKey(name), Description: description, QueryType: "header", Type: "string", Required: true})
+}
+
+// Response documents a status code, body is a value of the returned Go type or nil when there is no body
+func (o *OperationOverride) Response(status int, body interface{}) *OperationOverride {
+	response := OperationResponse{Description: http.StatusText(status)}
+	if body != nil {
+		schema := o.registry.schemaFromType(reflect.TypeOf(body), map[reflect.Type]bool{})
+		response.Schema = &schema
+	}
+
+	if o.responses == nil {
+		o.responses = map[string]OperationResponse{}
+	}
+
+	o.responses[strconv.Itoa(status)] = response
+	if bodyType, ok := o.registry.nameTypeFromType(reflect.TypeOf(body)); ok {
+		if o.types == nil {
+			o.types = map[string]NameType{}
+		}
+
+		o.types[strconv.Itoa(status)] = bodyType
+	}
+
+	return o
+}
+
+// nameTypeFromType names the Go type of a body so that generated clients can reuse it, or declare it with its fields
+func (o *Overrides) nameTypeFromType(t reflect.Type) (result NameType, ok bool) {
+	if t == nil {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Slice {
+		result.IsArray = true
+		t = t.Elem()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+
+	if len(t.Name()) == 0 || len(t.PkgPath()) == 0 {
+		return
+	}
+
+	result.Name = t.Name()
+	result.StructName = t.String()
+	result.ImportPath = t.PkgPath()
+	if t.Kind() != reflect.Struct {
+		result.Type = jsonMapping[t.Kind().String()]
+	}
+
+	result.Children = o.fieldsFromType(t, map[reflect.Type]bool{})
+	return result, true
+}
+
+// fieldsFromType lists the json fields of a struct like addStructProperties does, recursive types are only named
+func (o *Overrides) fieldsFromType(t reflect.Type, visiting map[reflect.Type]bool) (children []NameType) {
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		options := strings.Split(field.Tag.Get("json"), ",")
+		name := options[0]
+		if name == "-" || (len(field.PkgPath) > 0 && !field.Anonymous) {
+			continue
+		}
+
+		fieldType := field.Type
+		optional := containsString(options[1:], "omitempty")
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			optional = true
+		}
+
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && len(name) == 0 {
+			children = append(children, o.fieldsFromType(fieldType, visiting)...)
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		child := NameType{Name: name, Optional: optional}
+		if (fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array) && fieldType.Elem().Kind() != reflect.Uint8 {
+			child.IsArray = true
+			fieldType = fieldType.Elem()
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+		}
+
+		_, overridden := o.lookupSchema(fieldType.String())
+		switch {
+		case overridden || (fieldType.Kind() == reflect.Struct && len(fieldType.Name()) > 0):
+			child.StructName = fieldType.String()
+			child.ImportPath = fieldType.PkgPath()
+			if !overridden {
+				child.Children = o.fieldsFromType(fieldType, visiting)
+			}
+		case fieldType.Kind() == reflect.Struct:
+			child.Type = "object"
+			child.Children = o.fieldsFromType(fieldType, visiting)
+		case fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array:
+			child.Type = "string"
+		case fieldType.Kind() == reflect.Map:
+			child.Type = "object"
+		default:
+			child.Type = jsonMapping[fieldType.Kind().String()]
+		}
+
+		children = append(children, child)
+	}
+
+	return
+}
+
+// Param adds a parameter, replacing the inferred one with the same name and location
+func (o *OperationOverride) Param(parameter InputParameter) *OperationOverride {
+	o.parameters = append(o.parameters, parameter)
+	return o
+}
+
+func (o *OperationOverride) matches(rh RouteHolder) bool {
+	if len(o.middleware) > 0 {
+		return containsString(rh.Middlewares, o.middleware)
+	}
+
+	if len(o.handler) > 0 {
+		return o.handler == rh.Handler
+	}
+
+	if o.route != normalizeTemplate(rh.Route) {
+		return false
+	}
+
+	return len(o.method) == 0 || containsString(rh.Methods, o.method)
+}
+
+// apply merges the registered overrides on top of the inferred routes, middlewares first
+// and then the others in the order they were registered
+func (o *Overrides) apply(routes []RouteHolder) []RouteHolder {
+	o = o.orDefault()
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.operations) == 0 {
+		return routes
+	}
+
+	ordered := make([]*OperationOverride, 0, len(o.operations))
+	for _, override := range o.operations {
+		if len(override.middleware) > 0 {
+			ordered = append(ordered, override)
+		}
+	}
+
+	for _, override := range o.operations {
+		if len(override.middleware) == 0 {
+			ordered = append(ordered, override)
+		}
+	}
+
+	result := make([]RouteHolder, len(routes))
+	for i, rh := range routes {
+		for _, override := range ordered {
+			if !override.matches(rh) {
+				continue
+			}
+
+			if len(override.summary) > 0 {
+				rh.Summary = override.summary
+			}
+
+			if len(override.description) > 0 {
+				rh.Description = override.description
+			}
+
+			if len(override.operationID) > 0 {
+				rh.OperationID = override.operationID
+			}
+
+			if len(override.tags) > 0 {
+				rh.Tags = override.tags
+			}
+
+			if len(override.responses) > 0 {
+				responses := map[string]OperationResponse{}
+				for status, response := range rh.Responses {
+					responses[status] = response
+				}
+
+				for status, response := range override.responses {
+					responses[status] = response
+				}
+
+				rh.Responses = responses
+				types := map[string]NameType{}
+				for status, bodyType := range rh.ResponseTypes {
+					if _, ok := override.responses[status]; !ok {
+						types[status] = bodyType
+					}
+				}
+
+				for status, bodyType := range override.types {
+					types[status] = bodyType
+				}
+
+				rh.ResponseTypes = types
+			}
+
+			rh.Parameters = append(append([]InputParameter{}, rh.Parameters...), override.parameters...)
+			rh.Deprecated = rh.Deprecated || override.deprecated
+			if len(override.security) > 0 {
+				rh.Security = override.security
+			}
+		}
+
+		result[i] = rh
+	}
+
+	return result
+}
+
+// mergeParameters replaces the inferred parameters by the overridden ones with the same name and location
+func mergeParameters(parameters, overridden []InputParameter) []InputParameter {
+	for _, parameter := range overridden {
+		replaced := false
+		for i := range parameters {
+			if parameters[i].Name == parameter.Name && parameters[i].QueryType == parameter.QueryType {
+				parameters[i] = parameter
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	return parameters
+}
+
+func (o *Overrides) lookupSchema(typeName string) (schema SchemaParameters, ok bool) {
+	o = o.orDefault()
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	schema, ok = o.schemas[strings.TrimPrefix(typeName, "*")]
+	return
+}
+
+// schemaFromType documents a Go type through reflection using its json tags,
+// a struct already being documented higher up is only described as an object to stop recursive types
+func (o *Overrides) schemaFromType(t reflect.Type, visiting map[reflect.Type]bool) SchemaParameters {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if schema, ok := o.lookupSchema(t.String()); ok {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return SchemaParameters{Type: "string"}
+		}
+
+		items := o.schemaFromType(t.Elem(), visiting)
+		return SchemaParameters{Type: "array", Items: &items}
+	case reflect.Map:
+		return SchemaParameters{Type: "object"}
+	case reflect.Struct:
+		if visiting[t] {
+			return SchemaParameters{Type: "object"}
+		}
+
+		properties := map[string]SchemaParameters{}
+		o.addStructProperties(t, properties, visiting)
+		return SchemaParameters{Type: "object", Properties: properties}
+	case reflect.Interface:
+		return SchemaParameters{}
+	}
+
+	return SchemaParameters{Type: jsonMapping[t.Kind().String()]}
+}
+
+func (o *Overrides) addStructProperties(t reflect.Type, properties map[string]SchemaParameters, visiting map[reflect.Type]bool) {
+	if visiting[t] {
+		return
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || (len(field.PkgPath) > 0 && !field.Anonymous) {
+			continue
+		}
+
+		//embedded structs without a json name are flattened like encoding/json does
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && len(name) == 0 {
+			o.addStructProperties(field.Type, properties, visiting)
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		properties[name] = o.schemaFromType(field.Type, visiting)
+	}
+}
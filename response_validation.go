@@ -0,0 +1,201 @@
+package summerfish
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ResponseValidationOptions configures ResponseValidationMiddleware.
+// Invalid responses are passed to Report, which logs them by default, with Fail they are replaced by a 500 problem response.
+type ResponseValidationOptions struct {
+	Fail   bool
+	Report func(r *http.Request, problem Problem)
+}
+
+// ResponseValidationMiddleware captures the responses of the handlers and validates them against the documented
+// responses of the operation, reporting undocumented status codes and bodies which don't match their schema.
+// Responses are buffered until the handler returns, so it is meant for development, staging and tests.
+// Upgraded connections and responses the handler flushes or hijacks are passed through without validation.
+func ResponseValidationMiddleware(s *SchemeHolder, options ResponseValidationOptions) mux.MiddlewareFunc {
+	if options.Report == nil {
+		options.Report = func(r *http.Request, problem Problem) {
+			log.Printf("summerfish: %s %s response: %s", r.Method, r.URL.Path, problem.Detail)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get("Upgrade")) > 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, header: http.Header{}}
+			next.ServeHTTP(recorder, r)
+			if recorder.streamed {
+				return
+			}
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			problem, ok := ValidateResponse(s, r, recorder.status, recorder.header, recorder.body.Bytes())
+			if !ok {
+				options.Report(r, problem)
+				if options.Fail {
+					writeProblem(w, problem)
+					return
+				}
+			}
+
+			for name, values := range recorder.header {
+				w.Header()[name] = values
+			}
+
+			w.WriteHeader(recorder.status)
+			w.Write(recorder.body.Bytes())
+		})
+	}
+}
+
+// ValidateResponse checks a response against the documented responses of the operation of the request,
+// e.g. the Code, Header() and Body of an httptest.ResponseRecorder.
+// Requests which don't match a documented operation are accepted.
+func ValidateResponse(s *SchemeHolder, r *http.Request, status int, header http.Header, body []byte) (problem Problem, ok bool) {
+	operation, _, found := findOperation(s, r)
+	if !found {
+		return problem, true
+	}
+
+	return ValidateOperationResponse(operation, r, status, header, body)
+}
+
+// ValidateOperationResponse checks a response against the documented responses of a known operation,
+// e.g. one of s.Paths, without looking the operation up from the request
+func ValidateOperationResponse(operation Operation, r *http.Request, status int, header http.Header, body []byte) (problem Problem, ok bool) {
+	problem = Problem{Type: "about:blank", Status: http.StatusInternalServerError, Instance: r.URL.Path}
+	response, documented := operation.Responses[strconv.Itoa(status)]
+	if !documented {
+		response, documented = operation.Responses["default"]
+	}
+
+	if !documented {
+		problem.add("status", strconv.Itoa(status), "is not documented")
+	} else if response.Schema != nil && len(bytes.TrimSpace(body)) > 0 {
+		validateResponseBody(*response.Schema, header, body, &problem)
+	}
+
+	if len(problem.Errors) == 0 {
+		return problem, true
+	}
+
+	problem.summarize()
+	return problem, false
+}
+
+func validateResponseBody(schema SchemaParameters, header http.Header, body []byte, problem *Problem) {
+	if contentType := header.Get(contentTypeHeader); len(contentType) > 0 && (schema.Type == "object" || schema.Type == "array") {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !strings.HasSuffix(mediaType, "json") {
+			problem.add("header", contentTypeHeader, fmt.Sprintf("must be a JSON media type, not %s", contentType))
+			return
+		}
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		//plain text responses of string schemas are not JSON encoded
+		if schema.Type != "string" {
+			problem.add("response", "", "must be valid JSON: "+err.Error())
+		}
+
+		return
+	}
+
+	validateSchema(value, schema, "response", "", problem)
+}
+
+// responseRecorder buffers a response so it can be validated before it is sent,
+// once it is streamed the writes go to the underlying ResponseWriter
+type responseRecorder struct {
+	http.ResponseWriter
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	streamed bool
+}
+
+func (rr *responseRecorder) Header() http.Header {
+	if rr.streamed {
+		return rr.ResponseWriter.Header()
+	}
+
+	return rr.header
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.streamed {
+		rr.ResponseWriter.WriteHeader(status)
+	} else if rr.status == 0 {
+		rr.status = status
+	}
+}
+
+func (rr *responseRecorder) Write(content []byte) (int, error) {
+	if rr.streamed {
+		return rr.ResponseWriter.Write(content)
+	}
+
+	rr.WriteHeader(http.StatusOK)
+	return rr.body.Write(content)
+}
+
+// stream sends what was buffered and passes the rest of the response through
+func (rr *responseRecorder) stream() {
+	if rr.streamed {
+		return
+	}
+
+	rr.streamed = true
+	for name, values := range rr.header {
+		rr.ResponseWriter.Header()[name] = values
+	}
+
+	if rr.status != 0 {
+		rr.ResponseWriter.WriteHeader(rr.status)
+	}
+
+	rr.ResponseWriter.Write(rr.body.Bytes())
+}
+
+// Flush streams the response, streamed responses are not validated since they may never end
+func (rr *responseRecorder) Flush() {
+	rr.stream()
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, nothing written on it is validated
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+
+	rr.streamed = true
+	return hijacker.Hijack()
+}
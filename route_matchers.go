@@ -0,0 +1,188 @@
+package summerfish
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type templateVariable struct {
+	Name    string
+	Pattern string
+}
+
+const contentTypeHeader = "Content-Type"
+
+// parseTemplateVariables extracts the {name} and {name:pattern} entries of a gorilla template
+func parseTemplateVariables(template string) (vars []templateVariable) {
+	level, start := 0, 0
+	for i := 0; i < len(template); i++ {
+		switch template[i] {
+		case '{':
+			if level == 0 {
+				start = i
+			}
+			level++
+		case '}':
+			level--
+			if level != 0 {
+				continue
+			}
+
+			parts := strings.SplitN(template[start+1:i], ":", 2)
+			variable := templateVariable{Name: strings.TrimSpace(parts[0])}
+			if len(parts) > 1 {
+				variable.Pattern = "^" + parts[1] + "$"
+			}
+
+			vars = append(vars, variable)
+		}
+	}
+
+	return
+}
+
+// normalizeTemplate removes the variable patterns, turning {id:[0-9]+} into {id}
+func normalizeTemplate(template string) string {
+	var builder strings.Builder
+	level, isPattern := 0, false
+	for _, letter := range template {
+		switch {
+		case letter == '{':
+			level++
+			if level == 1 {
+				isPattern = false
+				builder.WriteRune(letter)
+				continue
+			}
+		case letter == '}':
+			level--
+			if level == 0 {
+				builder.WriteRune(letter)
+				continue
+			}
+		case letter == ':' && level == 1:
+			isPattern = true
+		}
+
+		if level == 0 || !isPattern {
+			builder.WriteRune(letter)
+		}
+	}
+
+	return builder.String()
+}
+
+func getQueriesFromRoute(route *mux.Route) (queries []NameType, err error) {
+	templates, err := route.GetQueriesTemplates()
+	if err != nil {
+		if err.Error() == "mux: route doesn't have queries" {
+			err = nil
+		}
+
+		return
+	}
+
+	queries = getQueriesFromTemplates(templates)
+	return
+}
+
+func getQueriesFromTemplates(templates []string) (queries []NameType) {
+	for _, template := range templates {
+		split := strings.SplitN(template, "=", 2)
+		query := NameType{Name: split[0], Type: "string", IsRequired: true}
+		if len(split) > 1 {
+			vars := parseTemplateVariables(split[1])
+			if len(vars) == 1 && strings.HasPrefix(split[1], "{") && strings.HasSuffix(split[1], "}") {
+				query.Pattern = vars[0].Pattern
+			} else if len(vars) == 0 && len(split[1]) > 0 {
+				query.Enum = []string{split[1]}
+			}
+		}
+
+		queries = append(queries, query)
+	}
+
+	return
+}
+
+// getMatchersFromRoute reads the header and scheme matchers, which gorilla mux does not expose
+func getMatchersFromRoute(route *mux.Route) (headers []NameType, schemes []string) {
+	matchers := reflect.ValueOf(route).Elem().FieldByName("matchers")
+	if !matchers.IsValid() {
+		return
+	}
+
+	for i := 0; i < matchers.Len(); i++ {
+		matcher := matchers.Index(i).Elem()
+		switch matcher.Type().Name() {
+		case "headerMatcher":
+			for _, key := range matcher.MapKeys() {
+				header := NameType{Name: http.CanonicalHeaderKey(key.String()), Type: "string", IsRequired: true}
+				if value := matcher.MapIndex(key).String(); len(value) > 0 {
+					header.Enum = []string{value}
+				}
+
+				headers = append(headers, header)
+			}
+		case "headerRegexMatcher":
+			for _, key := range matcher.MapKeys() {
+				header := NameType{Name: http.CanonicalHeaderKey(key.String()), Type: "string", IsRequired: true}
+				if expr := matcher.MapIndex(key); !expr.IsNil() {
+					header.Pattern = expr.Elem().FieldByName("expr").String()
+				}
+
+				headers = append(headers, header)
+			}
+		case "schemeMatcher":
+			for j := 0; j < matcher.Len(); j++ {
+				schemes = append(schemes, strings.ToLower(matcher.Index(j).String()))
+			}
+		}
+	}
+
+	//map iteration is random, sorting keeps the generated docs stable
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+
+	return
+}
+
+func getHostFromRoute(route *mux.Route) (host string, err error) {
+	host, err = route.GetHostTemplate()
+	if err != nil && err.Error() == "mux: route doesn't have a host" {
+		err = nil
+	}
+
+	return
+}
+
+// addRouteMatchers merges the inputs required by the route matchers with the ones found in the source code
+func (rh *RouteHolder) addRouteMatchers(rp RouteParser) {
+	rh.Host = rp.Host
+	rh.Schemes = rp.Schemes
+	rh.Headers = rp.Headers
+	rh.Subrouters = rp.Subrouters
+	rh.Middlewares = rp.Middlewares
+	for _, query := range rp.Queries {
+		found := false
+		for i := range rh.Query {
+			if rh.Query[i].Name != query.Name {
+				continue
+			}
+
+			found = true
+			rh.Query[i].IsRequired = true
+			rh.Query[i].Pattern = query.Pattern
+			rh.Query[i].Enum = query.Enum
+		}
+
+		if !found {
+			rh.Query = append(rh.Query, query)
+		}
+	}
+}
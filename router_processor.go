@@ -2,6 +2,7 @@ package summerfish
 
 import (
 	"bufio"
+	"fmt"
 	"go/build"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	kitHttp "github.com/go-kit/kit/transport/http"
@@ -23,6 +25,14 @@ type RouteParser struct {
 	LineNumber           int
 	Methods              []string
 	IsOnlyEndpointParser bool
+	Queries              []NameType
+	Headers              []NameType
+	Host                 string
+	Schemes              []string
+	Subrouters           []SubrouterInfo
+	Middlewares          []string
+	Diagnostics          []Diagnostic
+	overrides            *Overrides
 }
 
 type RoutePath struct {
@@ -32,14 +42,31 @@ type RoutePath struct {
 }
 
 type RouteHolder struct {
-	ID       int
-	Path     []NameType
-	Query    []NameType
-	Body     NameType
-	FormData []NameType
-	Route    string
-	Methods  []string
-	Name     string
+	ID             int
+	Path           []NameType
+	Query          []NameType
+	Body           NameType
+	FormData       []NameType
+	Route          string
+	Methods        []string
+	Name           string
+	Headers        []NameType
+	Host           string
+	Schemes        []string
+	Subrouters     []SubrouterInfo
+	Handler        string
+	Summary        string
+	Description    string
+	OperationID    string
+	Tags           []string
+	Responses      map[string]OperationResponse
+	Parameters     []InputParameter
+	Deprecated     bool
+	Middlewares    []string
+	RequestHeaders []string
+	UsesBasicAuth  bool
+	Security       []SecurityRequirement
+	ResponseTypes  map[string]NameType
 }
 
 type NameType struct {
@@ -48,6 +75,11 @@ type NameType struct {
 	IsArray    bool
 	Children   []NameType
 	IsRequired bool
+	Optional   bool
+	Pattern    string
+	Enum       []string
+	StructName string
+	ImportPath string
 }
 
 var nativeTypes = map[string]bool{
@@ -72,18 +104,36 @@ var nativeTypes = map[string]bool{
 	"complex128": true,
 }
 
+var (
+	functionNameRegex  = regexp.MustCompile(`func\s(\(.*\))?\s?(?U)(.*)\s?\(.*{`)
+	returnRegex        = regexp.MustCompile(`return.*(\.|\s)\s?(.*)\(`)
+	pathRegex          = regexp.MustCompile(`vars\["(.+?)"\]`)
+	queryRegex         = regexp.MustCompile(`r\.URL\.Query\(\).Get\("(.+)"\)`)
+	bodyRegex          = regexp.MustCompile(`json.NewDecoder\(r.Body\).Decode\((.+)\)`)
+	bodyFormFileRegex  = regexp.MustCompile(`r\.FormFile\("(.+)"\)`)
+	bodyFormValueRegex = regexp.MustCompile(`r\.FormValue\("(.+)"\)`)
+	structFieldRegex   = regexp.MustCompile("^\\s*(.+)\\b\\s+(.+)\\b(\\s+`(.+)`)?$")
+	jsonTagRegex       = regexp.MustCompile(`(?U)json:"(.+)"`)
+	headerReadRegex    = regexp.MustCompile(`r\.Header\.Get\(((?:[^()]|\([^()]*\))+)\)`)
+	constantNameRegex  = regexp.MustCompile(`^(\w+\.)?\w+$`)
+	basicAuthRegex     = regexp.MustCompile(`r\.BasicAuth\(\)`)
+)
+
 func processHandler(handler http.Handler) (RoutePath, RoutePath) {
 	v, ok := handler.(*kitHttp.Server)
 	if ok {
 		ptrDecoder := reflect.ValueOf(v).Elem().FieldByName("dec").Pointer()
 		ptrEndpoint := reflect.ValueOf(v).Elem().FieldByName("e").Pointer()
 		return getRoutePathForPointer(ptrDecoder), getRoutePathForPointer(ptrEndpoint)
-	} else {
-		ptrHolder := reflect.ValueOf(handler).Pointer()
-		return getRoutePathForPointer(ptrHolder), RoutePath{}
 	}
 
-	return RoutePath{}, RoutePath{}
+	//handlers implemented by struct values have no function to point to
+	value := reflect.ValueOf(handler)
+	if value.Kind() != reflect.Func && value.Kind() != reflect.Ptr {
+		return RoutePath{}, RoutePath{}
+	}
+
+	return getRoutePathForPointer(value.Pointer()), RoutePath{}
 }
 
 func getRoutePathForPointer(ptrHolder uintptr) (rp RoutePath) {
@@ -98,7 +148,6 @@ func getRoutePathForPointer(ptrHolder uintptr) (rp RoutePath) {
 }
 
 func (rp *RouteParser) processSourceFilesForEndpoint(lines []string) (rh RouteHolder) {
-	returnRegex, _ := regexp.Compile(`return.*(\.|\s)\s?(.*)\(`)
 	rh.Route = rp.Route
 	rh.Methods = rp.Methods
 	rh.ID = rp.ID
@@ -128,16 +177,11 @@ func (rp *RouteParser) processSourceFilesForEndpoint(lines []string) (rh RouteHo
 }
 
 func (rp *RouteParser) processSourceFiles(lines []string) (rh RouteHolder) {
-	functionNameRegex, _ := regexp.Compile(`func\s(\(.*\))?\s?(?U)(.*)\s?\(.*{`)
-	pathRegex, _ := regexp.Compile(`vars\["(.+?)"\]`)
-	queryRegex, _ := regexp.Compile(`r\.URL\.Query\(\).Get\("(.+)"\)`)
-	bodyRegex, _ := regexp.Compile(`json.NewDecoder\(r.Body\).Decode\((.+)\)`)
-	bodyFormFileRegex, _ := regexp.Compile(`r\.FormFile\("(.+)"\)`)
-	bodyFormValueRegex, _ := regexp.Compile(`r\.FormValue\("(.+)"\)`)
 
 	rh.Route = rp.Route
 	rh.Methods = rp.Methods
 	rh.ID = rp.ID
+	rh.Handler = rp.RelativePath
 
 	if rp.LineNumber > 0 {
 		functionNameResult := functionNameRegex.FindStringSubmatch(lines[rp.LineNumber-1])
@@ -189,6 +233,17 @@ func (rp *RouteParser) processSourceFiles(lines []string) (rh RouteHolder) {
 			rh.Body.Name = strings.Replace(bodyResult[1], "&", "", 1)
 		}
 
+		headerResult := headerReadRegex.FindStringSubmatch(lineText)
+		if len(headerResult) > 1 {
+			if name, ok := rp.resolveHeaderName(headerResult[1], lines); ok {
+				rh.RequestHeaders = append(rh.RequestHeaders, name)
+			}
+		}
+
+		if basicAuthRegex.MatchString(lineText) {
+			rh.UsesBasicAuth = true
+		}
+
 		bodyFormResult := bodyFormFileRegex.FindStringSubmatch(lineText)
 		if len(bodyFormResult) > 1 {
 			rh.FormData = append(rh.FormData, NameType{Name: bodyFormResult[1], Type: "file"})
@@ -202,29 +257,90 @@ func (rp *RouteParser) processSourceFiles(lines []string) (rh RouteHolder) {
 	return
 }
 
+// resolveHeaderName reads the name of a header from a string literal or a string constant of the package or its imports,
+// e.g. r.Header.Get(component.AuthHeader), other arguments are reported since the header can't be documented
+func (rp *RouteParser) resolveHeaderName(argument string, lines []string) (name string, ok bool) {
+	argument = strings.TrimSpace(argument)
+	if name, err := strconv.Unquote(argument); err == nil {
+		return name, true
+	}
+
+	if constantNameRegex.MatchString(argument) {
+		var files []string
+		if strings.Contains(argument, ".") {
+			files, _ = rp.searchForFullPath(argument, lines)
+		} else {
+			_, files, _ = rp.searchCurrentPackage(argument)
+		}
+
+		split := strings.Split(argument, ".")
+		declaration := regexp.MustCompile(`^\s*(const\s+)?` + regexp.QuoteMeta(split[len(split)-1]) + `(\s+string)?\s*=\s*("[^"]*"|` + "`[^`]*`" + `)`)
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+
+			for _, line := range strings.Split(string(content), "\n") {
+				if result := declaration.FindStringSubmatch(line); len(result) > 3 {
+					if name, err := strconv.Unquote(result[3]); err == nil {
+						return name, true
+					}
+				}
+			}
+		}
+	}
+
+	rp.Diagnostics = append(rp.Diagnostics, Diagnostic{
+		Severity: SeverityWarning,
+		Kind:     DiagnosticParameter,
+		Route:    rp.Route,
+		Methods:  rp.Methods,
+		File:     rp.FullPath,
+		Line:     rp.LineNumber,
+		Reason:   fmt.Sprintf("header read with %s is not documented, its name is neither a string literal nor a string constant", argument),
+	})
+
+	return
+}
+
 func (rp *RouteParser) searchForAll(name string, lines []string) NameType {
 	varType := rp.searchForType(name, lines)
 	if len(varType) == 0 {
 		return NameType{Name: name, Type: "string"}
 	}
 
+	return rp.resolveType(name, varType, lines)
+}
+
+// resolveType finds the definition of the type of name, structs are searched in the package or the imports of lines
+func (rp *RouteParser) resolveType(name, varType string, lines []string) NameType {
 	_, ok := nativeTypes[varType]
 	if ok {
 		return NameType{Name: name, Type: jsonMapping[varType]}
 	}
 
+	if _, ok := rp.overrides.lookupSchema(varType); ok {
+		return NameType{Name: name, StructName: varType}
+	}
+
 	var candidateSourceFiles = []string{}
+	var importPath string
 	var err error
 	if len(strings.Split(varType, ".")) <= 1 {
 		varType, candidateSourceFiles, err = rp.searchCurrentPackage(varType)
+		importPath = rp.packagePath()
 	} else {
-		candidateSourceFiles = rp.searchForFullPath(varType, lines)
+		candidateSourceFiles, importPath = rp.searchForFullPath(varType, lines)
 	}
 	if err != nil || len(candidateSourceFiles) == 0 {
+		rp.addDiagnostic(SeverityError, fmt.Sprintf("type %s of %s could not be resolved", varType, name))
 		return NameType{Name: name, Type: ""}
 	}
 
-	return rp.searchForStruct(varType, "", candidateSourceFiles, false)
+	result := rp.searchForStruct(varType, "", candidateSourceFiles, false)
+	result.ImportPath = importPath
+	return result
 }
 
 func (rp *RouteParser) searchForStruct(name string, childrenNameFromParent string, paths []string, isArray bool) (result NameType) {
@@ -239,6 +355,11 @@ func (rp *RouteParser) searchForStruct(name string, childrenNameFromParent strin
 	}
 
 	result.IsArray = isArray
+	result.StructName = name
+	if _, ok := rp.overrides.lookupSchema(name); ok {
+		return
+	}
+
 	for _, path := range paths {
 		children, isFinished := rp.searchForStructInOneFile(path, structPackage, structName, paths)
 		if len(children) > 0 {
@@ -250,11 +371,11 @@ func (rp *RouteParser) searchForStruct(name string, childrenNameFromParent strin
 		}
 	}
 
+	rp.addDiagnostic(SeverityError, fmt.Sprintf("struct %s was not found in %d source files", name, len(paths)))
 	return
 }
 
 func (rp *RouteParser) searchForStructInOneFile(path, structPackage, structName string, paths []string) (children []NameType, isFinished bool) {
-	bodyTypeRegex, _ := regexp.Compile("^\\s*(.+)\\b\\s+(.+)\\b(\\s+`(.+)`)?$")
 	formattedStructName := "type " + structName + " struct"
 
 	file, err := os.Open(path)
@@ -275,9 +396,11 @@ func (rp *RouteParser) searchForStructInOneFile(path, structPackage, structName
 				return
 			}
 
-			typeResult := bodyTypeRegex.FindStringSubmatch(lineText)
+			typeResult := structFieldRegex.FindStringSubmatch(lineText)
 			if len(typeResult) > 1 {
-				children = append(children, rp.findNativeType(structPackage, typeResult[1], typeResult[2], typeResult[3], paths))
+				if child, ok := rp.findNativeType(structPackage, typeResult[1], typeResult[2], typeResult[3], paths); ok {
+					children = append(children, child)
+				}
 			}
 		} else if strings.HasPrefix(lineText, formattedStructName) {
 			isFound = true
@@ -287,27 +410,40 @@ func (rp *RouteParser) searchForStructInOneFile(path, structPackage, structName
 	return
 }
 
-func (rp *RouteParser) findNativeType(structPackage string, varName, varType, varTags string, paths []string) (output NameType) {
-	jsonTagRegex, _ := regexp.Compile(`(?U)json:"(.+)"`)
+// findNativeType resolves a struct field, fields ignored by encoding/json are skipped and the ones
+// which may be missing from the json, pointers or omitempty, are marked as optional
+func (rp *RouteParser) findNativeType(structPackage string, varName, varType, varTags string, paths []string) (output NameType, ok bool) {
+	optional := false
 	if len(varTags) > 0 {
 		jsonResults := jsonTagRegex.FindStringSubmatch(varTags)
 		if len(jsonResults) > 1 {
 			splitResult := strings.Split(jsonResults[1], ",")
-			varName = splitResult[0]
+			if splitResult[0] == "-" && len(splitResult) == 1 {
+				return
+			}
+
+			if len(splitResult[0]) > 0 {
+				varName = splitResult[0]
+			}
+
+			optional = containsString(splitResult[1:], "omitempty")
 		}
 	}
 
 	isArray := false
+	if strings.HasPrefix(varType, "*") {
+		optional = true
+		varType = strings.TrimLeft(varType, "*")
+	}
 
 	//Array verification
 	if strings.HasPrefix(varType, "[]") {
 		isArray = true
-		varType = strings.SplitN(varType, "]", 2)[1]
+		varType = strings.TrimLeft(strings.SplitN(varType, "]", 2)[1], "*")
 	}
 
-	_, ok := nativeTypes[varType]
-	if ok {
-		return NameType{varName, varType, isArray, nil, false}
+	if _, native := nativeTypes[varType]; native {
+		return NameType{Name: varName, Type: varType, IsArray: isArray, Optional: optional}, true
 	}
 
 	//appends package name if internal
@@ -315,17 +451,34 @@ func (rp *RouteParser) findNativeType(structPackage string, varName, varType, va
 		varType = strings.Join([]string{structPackage, varType}, ".")
 	}
 
-	return rp.searchForStruct(varType, varName, paths, isArray)
+	output = rp.searchForStruct(varType, varName, paths, isArray)
+	output.Optional = optional
+	return output, true
 }
 
 func (rp *RouteParser) searchForType(name string, lines []string) string {
-	exp := "var " + name + " (.+)"
-	exp2 := name + " := (.+){"
-	exp3 := convertToCamelCase(name) + ".* := strconv\\.Parse(.*)\\("
+	exp := "var " + regexp.QuoteMeta(name) + " (.+)"
+	exp2 := regexp.QuoteMeta(name) + " := (.+){"
+	exp3 := regexp.QuoteMeta(convertToCamelCase(name)) + ".* := strconv\\.Parse(.*)\\("
+
+	bodyTypeRegex, err := regexp.Compile(exp)
+	if err != nil {
+		rp.addDiagnostic(SeverityError, err.Error())
+		return ""
+	}
+
+	bodyTypeRegex2, err := regexp.Compile(exp2)
+	if err != nil {
+		rp.addDiagnostic(SeverityError, err.Error())
+		return ""
+	}
+
+	bodyTypeRegex3, err := regexp.Compile("(?iU)" + exp3)
+	if err != nil {
+		rp.addDiagnostic(SeverityError, err.Error())
+		return ""
+	}
 
-	bodyTypeRegex, _ := regexp.Compile(exp)
-	bodyTypeRegex2, _ := regexp.Compile(exp2)
-	bodyTypeRegex3, _ := regexp.Compile("(?iU)" + exp3)
 	for i := rp.LineNumber; i < len(lines); i++ {
 		lineText := lines[i]
 		typeResult := bodyTypeRegex.FindStringSubmatch(lineText)
@@ -346,10 +499,15 @@ func (rp *RouteParser) searchForType(name string, lines []string) string {
 	return ""
 }
 
-func (rp *RouteParser) searchForFullPath(name string, lines []string) (result []string) {
+func (rp *RouteParser) searchForFullPath(name string, lines []string) (result []string, importPath string) {
 	splitName := strings.Split(name, ".")[0]
-	exp := "\"(.+/" + splitName + ")\"$"
-	regex, _ := regexp.Compile(exp)
+	exp := "\"(.+/" + regexp.QuoteMeta(splitName) + ")\"$"
+	regex, err := regexp.Compile(exp)
+	if err != nil {
+		rp.addDiagnostic(SeverityError, err.Error())
+		return
+	}
+
 	for i := 0; i < len(lines); i++ {
 		lineText := lines[i]
 		if strings.HasPrefix(lineText, "func") {
@@ -364,12 +522,23 @@ func (rp *RouteParser) searchForFullPath(name string, lines []string) (result []
 			}
 
 			result = append(result, values...)
+			importPath = path[1]
 		}
 	}
 
 	return
 }
 
+// packagePath is the import path of the package declaring the handler
+func (rp *RouteParser) packagePath() string {
+	lastDotIndex := strings.LastIndex(rp.RelativePath, ".")
+	if lastDotIndex <= 0 {
+		return ""
+	}
+
+	return rp.RelativePath[:lastDotIndex]
+}
+
 func (rp *RouteParser) searchCurrentPackage(varType string) (completeVarType string, result []string, err error) {
 	lastDotIndex := strings.LastIndex(rp.RelativePath, ".")
 	if lastDotIndex == 0 {
@@ -395,6 +564,18 @@ func (rp *RouteParser) getFilesFromPath(path string) (result []string, err error
 	}
 
 	files, err := ioutil.ReadDir(fullPath)
+	if os.IsNotExist(err) {
+		//outside of the GOPATH the package is located with the module of the source file
+		var pkg *build.Package
+		pkg, err = build.Import(path, filepath.Dir(rp.FullPath), build.FindOnly)
+		if err != nil {
+			return
+		}
+
+		fullPath = pkg.Dir
+		files, err = ioutil.ReadDir(fullPath)
+	}
+
 	if err != nil {
 		return
 	}
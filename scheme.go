@@ -9,12 +9,30 @@ import (
 )
 
 type SchemeHolder struct {
-	SwaggerVersion string            `json:"swagger" yaml:"swagger"`
-	Information    SchemeInformation `json:"info" yaml:"info"`
-	Host           string            `json:"host,omitempty" yaml:"host,omitempty"`
-	BasePath       string            `json:"basePath" yaml:"basePath"`
-	Schemes        []string          `json:"schemes"`
-	Paths          PathsHolder       `json:"paths"`
+	SwaggerVersion      string                    `json:"swagger" yaml:"swagger"`
+	Information         SchemeInformation         `json:"info" yaml:"info"`
+	Host                string                    `json:"host,omitempty" yaml:"host,omitempty"`
+	BasePath            string                    `json:"basePath" yaml:"basePath"`
+	Schemes             []string                  `json:"schemes"`
+	Paths               PathsHolder               `json:"paths"`
+	Servers             []ServerObject            `json:"x-servers,omitempty" yaml:"x-servers,omitempty"`
+	SecurityDefinitions map[string]SecurityScheme `json:"securityDefinitions,omitempty" yaml:"securityDefinitions,omitempty"`
+	Security            []SecurityRequirement     `json:"security,omitempty" yaml:"security,omitempty"`
+	Coverage            *CoverageSummary          `json:"x-summerfish-coverage,omitempty" yaml:"x-summerfish-coverage,omitempty"`
+	Overrides           *Overrides                `json:"-" yaml:"-"`
+	extensions          []documentExtension
+	generated           *SchemeHolder
+}
+
+// ServerObject describes a templated host, swagger 2.0 has no servers so it is emitted as an extension
+type ServerObject struct {
+	URL       string                    `json:"url"`
+	Variables map[string]ServerVariable `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
+
+type ServerVariable struct {
+	Default string `json:"default"`
+	Pattern string `json:"x-pattern,omitempty" yaml:"x-pattern,omitempty"`
 }
 
 type SchemeInformation struct {
@@ -46,19 +64,25 @@ var jsonMapping = map[string]string{
 
 var link = regexp.MustCompile("(^[A-Za-z])|_([A-Za-z])")
 var versionRegex = regexp.MustCompile(`v\d+`)
+var numericPatternRegex = regexp.MustCompile(`^\^?(-\?)?((\[0-9\]|\[1-9\]|\\d)(\+|\*|\{\d+(,\d*)?\})?)+\$?$`)
 
-func mapRoutesToPaths(routerHolders []RouteHolder, prefix string) PathsHolder {
+func mapRoutesToPaths(routerHolders []RouteHolder, prefix string, overrides *Overrides) PathsHolder {
 	paths := PathsHolder{}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	//the variables of a templated base path are not part of the path templates of the document
+	prefixVariables := map[string]bool{}
+	for _, variable := range parseTemplateVariables(prefix) {
+		prefixVariables[variable.Name] = true
+	}
+
 	for i, router := range routerHolders {
 		if len(router.Methods) == 0 {
 			continue
 		}
 
-		if strings.HasSuffix(prefix, "/") {
-			prefix = prefix[0 : len(prefix)-1]
-		}
-
-		router.Route = strings.TrimPrefix(router.Route, prefix)
+		templateVariables := parseTemplateVariables(router.Route)
+		router.Route = trimBasePath(router.Route, prefix)
 		if _, ok := paths[router.Route]; !ok {
 			paths[router.Route] = Method{}
 		}
@@ -66,15 +90,27 @@ func mapRoutesToPaths(routerHolders []RouteHolder, prefix string) PathsHolder {
 		//Must be initialized like this so that empty converts to json properly
 		parameters := []InputParameter{}
 		for _, entry := range router.Query {
-			parameters = append(parameters, generateInputParameter("query", entry.Name, entry.Type, false))
+			parameters = append(parameters, generateMatcherParameter("query", entry, entry.IsRequired))
 		}
 
-		for _, entry := range router.Path {
-			parameters = append(parameters, generateInputParameter("path", entry.Name, entry.Type, true))
+		var consumes []string
+		for _, entry := range router.Headers {
+			if entry.Name == contentTypeHeader && len(entry.Enum) > 0 {
+				consumes = entry.Enum
+				continue
+			}
+
+			parameters = append(parameters, generateMatcherParameter("header", entry, true))
+		}
+
+		for _, entry := range mergePathVariables(router.Path, templateVariables) {
+			if !prefixVariables[entry.Name] {
+				parameters = append(parameters, generateMatcherParameter("path", entry, true))
+			}
 		}
 
 		if len(router.Body.Name) > 0 {
-			parameters = append(parameters, mapBodyRoute(router.Body))
+			parameters = append(parameters, overrides.mapBodyRoute(router.Body))
 		}
 
 		hasFormData := false
@@ -83,25 +119,101 @@ func mapRoutesToPaths(routerHolders []RouteHolder, prefix string) PathsHolder {
 			hasFormData = true
 		}
 
-		tag := strings.Replace(getTagFromRoute(router.Route), "-", "_", -1)
+		tag := getTagFromSubrouters(router.Subrouters)
+		if len(tag) == 0 {
+			tag = getTagFromRoute(router.Route)
+		}
+
+		tag = strings.Replace(tag, "-", "_", -1)
 		operation := Operation{
-			ID:         fmt.Sprintf("%s_%d", router.Name, i),
-			Summary:    convertFromCamelCase(router.Name),
-			Parameters: parameters,
-			Tags:       []string{convertToCamelCase(tag)},
-			Responses:  map[string]OperationResponse{"200": OperationResponse{Description: "successful operation"}},
+			ID:          fmt.Sprintf("%s_%d", router.Name, i),
+			Summary:     convertFromCamelCase(router.Name),
+			Description: router.Description,
+			Parameters:  mergeParameters(parameters, router.Parameters),
+			Tags:        []string{convertToCamelCase(tag)},
+			Responses:   map[string]OperationResponse{"200": OperationResponse{Description: "successful operation"}},
+		}
+
+		//values set by annotations or overrides take precedence over the inferred ones
+		if len(router.OperationID) > 0 {
+			operation.ID = router.OperationID
+		}
+
+		if len(router.Summary) > 0 {
+			operation.Summary = router.Summary
+		}
+
+		if len(router.Tags) > 0 {
+			operation.Tags = router.Tags
+		}
+
+		//the default response is kept unless another successful one is documented
+		for status := range router.Responses {
+			if strings.HasPrefix(status, "2") {
+				delete(operation.Responses, "200")
+			}
+		}
+
+		for status, response := range router.Responses {
+			operation.Responses[status] = response
 		}
 
 		if hasFormData {
 			operation.Consumes = []string{"multipart/form-data"}
+		} else if len(consumes) > 0 {
+			operation.Consumes = consumes
 		}
 
+		operation.Schemes = router.Schemes
+		operation.Deprecated = router.Deprecated
+		operation.Security = router.Security
+
 		paths[router.Route][strings.ToLower(router.Methods[0])] = operation
 	}
 
 	return paths
 }
 
+func mapRoutesToServers(routerHolders []RouteHolder, schemes []string, basePath string) (servers []ServerObject) {
+	hosts := map[string]bool{}
+	for _, router := range routerHolders {
+		if len(router.Host) == 0 || hosts[router.Host] {
+			continue
+		}
+
+		hosts[router.Host] = true
+		var variables map[string]ServerVariable
+		for _, variable := range parseTemplateVariables(router.Host) {
+			if variables == nil {
+				variables = map[string]ServerVariable{}
+			}
+
+			variables[variable.Name] = ServerVariable{Default: variable.Name, Pattern: variable.Pattern}
+		}
+
+		hostSchemes := router.Schemes
+		if len(hostSchemes) == 0 {
+			hostSchemes = schemes
+		}
+
+		if len(hostSchemes) == 0 {
+			hostSchemes = []string{"http"}
+		}
+
+		for _, scheme := range hostSchemes {
+			servers = append(servers, ServerObject{URL: scheme + "://" + normalizeTemplate(router.Host) + basePath, Variables: variables})
+		}
+	}
+
+	return
+}
+
+// trimBasePath removes the base path from a route once both are normalized,
+// so that the variables of a templated base path match whatever pattern either of them declares
+func trimBasePath(route, basePath string) string {
+	return strings.TrimPrefix(normalizeTemplate(route), normalizeTemplate(strings.TrimSuffix(basePath, "/")))
+}
+
 func getTagFromRoute(route string) string {
 	split := strings.Split(route, "/")
 	if len(split) == 0 {
@@ -120,17 +232,25 @@ func getTagFromRoute(route string) string {
 	return split[2]
 }
 
-func mapBodyRoute(bodyField NameType) (result InputParameter) {
+func (o *Overrides) mapBodyRoute(bodyField NameType) (result InputParameter) {
 	result = generateInputParameter("body", bodyField.Name, "", true)
-	result.Schema = mapInternalParameters(bodyField)
+	result.Schema = o.mapInternalParameters(bodyField)
 	return
 }
 
-func mapInternalParameters(bodyField NameType) SchemaParameters {
+func (o *Overrides) mapInternalParameters(bodyField NameType) SchemaParameters {
+	if schema, ok := o.lookupSchema(bodyField.StructName); ok {
+		if bodyField.IsArray {
+			return SchemaParameters{Type: "array", Items: &schema}
+		}
+
+		return schema
+	}
+
 	props := make(map[string]SchemaParameters)
 	for _, param := range bodyField.Children {
-		if len(param.Children) > 0 {
-			props[param.Name] = mapInternalParameters(param)
+		if len(param.Children) > 0 || len(param.StructName) > 0 {
+			props[param.Name] = o.mapInternalParameters(param)
 
 		} else {
 			mappedParamType, ok := jsonMapping[param.Type]
@@ -172,6 +292,47 @@ func generateInputParameter(queryType, name, varType string, isRequired bool) In
 	return ip
 }
 
+// mergePathVariables keeps the variables read from the handler and adds the ones only declared in the route template
+func mergePathVariables(path []NameType, templateVariables []templateVariable) (result []NameType) {
+	used := map[string]bool{}
+	for _, variable := range templateVariables {
+		entry := NameType{Name: variable.Name, Type: "string"}
+		for _, pathEntry := range path {
+			if pathEntry.Name == variable.Name {
+				entry = pathEntry
+				break
+			}
+		}
+
+		entry.Pattern = variable.Pattern
+		used[variable.Name] = true
+		result = append(result, entry)
+	}
+
+	for _, entry := range path {
+		if !used[entry.Name] {
+			result = append(result, entry)
+		}
+	}
+
+	return
+}
+
+func inferTypeFromPattern(varType, pattern string) string {
+	if (len(varType) == 0 || varType == "string") && numericPatternRegex.MatchString(pattern) {
+		return "integer"
+	}
+
+	return varType
+}
+
+func generateMatcherParameter(queryType string, entry NameType, isRequired bool) InputParameter {
+	ip := generateInputParameter(queryType, entry.Name, inferTypeFromPattern(entry.Type, entry.Pattern), isRequired)
+	ip.Pattern = entry.Pattern
+	ip.Enum = entry.Enum
+	return ip
+}
+
 func convertToCamelCase(str string) string {
 	return link.ReplaceAllStringFunc(str, func(s string) string {
 		return strings.ToUpper(strings.Replace(s, "_", "", -1))
@@ -0,0 +1,140 @@
+package summerfish
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+const authorizationHeader = "Authorization"
+
+// SecurityScheme is a swagger security definition.
+// Routes wrapped by Middleware, or whose handler reads the header or query parameter of an apiKey
+// scheme (or calls r.BasicAuth for basic schemes), are documented as requiring it.
+type SecurityScheme struct {
+	Type             string             `json:"type"`
+	Description      string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Name             string             `json:"name,omitempty" yaml:"name,omitempty"`
+	In               string             `json:"in,omitempty" yaml:"in,omitempty"`
+	Flow             string             `json:"flow,omitempty" yaml:"flow,omitempty"`
+	AuthorizationURL string             `json:"authorizationUrl,omitempty" yaml:"authorizationUrl,omitempty"`
+	TokenURL         string             `json:"tokenUrl,omitempty" yaml:"tokenUrl,omitempty"`
+	Scopes           map[string]string  `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	Middleware       mux.MiddlewareFunc `json:"-" yaml:"-"`
+}
+
+// SecurityRequirement maps the names of security definitions to the scopes required, empty for non oauth2 schemes
+type SecurityRequirement map[string][]string
+
+func APIKeyHeader(name string) SecurityScheme {
+	return SecurityScheme{Type: "apiKey", In: "header", Name: name}
+}
+
+func APIKeyQuery(name string) SecurityScheme {
+	return SecurityScheme{Type: "apiKey", In: "query", Name: name}
+}
+
+// BearerJWT documents a JWT sent in the Authorization header, swagger 2.0 has no bearer type so it is an apiKey
+func BearerJWT() SecurityScheme {
+	return SecurityScheme{Type: "apiKey", In: "header", Name: authorizationHeader, Description: "JWT sent as: Bearer <token>"}
+}
+
+func BasicAuth() SecurityScheme {
+	return SecurityScheme{Type: "basic"}
+}
+
+// OAuth2 documents an oauth2 flow: implicit, password, application or accessCode
+func OAuth2(flow, authorizationURL, tokenURL string, scopes map[string]string) SecurityScheme {
+	return SecurityScheme{Type: "oauth2", Flow: flow, AuthorizationURL: authorizationURL, TokenURL: tokenURL, Scopes: scopes}
+}
+
+// WithMiddleware marks the routes wrapped by the middleware as requiring the scheme
+func (scheme SecurityScheme) WithMiddleware(middleware mux.MiddlewareFunc) SecurityScheme {
+	scheme.Middleware = middleware
+	return scheme
+}
+
+// getMiddlewaresFromRouters names the middlewares registered with Use on the routers a route is nested in
+func getMiddlewaresFromRouters(routers []*mux.Router) (middlewares []string) {
+	for _, router := range routers {
+		value := reflect.ValueOf(router).Elem().FieldByName("middlewares")
+		for i := 0; value.IsValid() && i < value.Len(); i++ {
+			if name := getFunctionName(value.Index(i).Elem()); len(name) > 0 {
+				middlewares = append(middlewares, name)
+			}
+		}
+	}
+
+	return
+}
+
+// getFunctionName identifies functions, closures created by the same factory share the name, e.g. pkg.Auth.func1
+func getFunctionName(value reflect.Value) string {
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return ""
+	}
+
+	function := runtime.FuncForPC(value.Pointer())
+	if function == nil {
+		return ""
+	}
+
+	return function.Name()
+}
+
+// inferSecurity requires the schemes whose middleware wraps the route or whose credentials are read by the handler
+func inferSecurity(rh RouteHolder, definitions map[string]SecurityScheme) (requirements []SecurityRequirement) {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		scheme := definitions[name]
+		required := false
+		if scheme.Middleware != nil {
+			required = containsString(rh.Middlewares, getFunctionName(reflect.ValueOf(scheme.Middleware)))
+		}
+
+		switch {
+		case scheme.Type == "basic":
+			required = required || rh.UsesBasicAuth
+		case scheme.Type == "apiKey" && scheme.In == "header":
+			for _, header := range rh.RequestHeaders {
+				required = required || http.CanonicalHeaderKey(header) == http.CanonicalHeaderKey(scheme.Name)
+			}
+		case scheme.Type == "apiKey" && scheme.In == "query":
+			for _, query := range rh.Query {
+				required = required || query.Name == scheme.Name
+			}
+		}
+
+		if required {
+			requirements = append(requirements, SecurityRequirement{name: []string{}})
+		}
+	}
+
+	return
+}
+
+// applySecurity documents the inferred requirements of the routes which don't have one already
+func applySecurity(routes []RouteHolder, definitions map[string]SecurityScheme) []RouteHolder {
+	if len(definitions) == 0 {
+		return routes
+	}
+
+	result := make([]RouteHolder, len(routes))
+	for i, rh := range routes {
+		if len(rh.Security) == 0 {
+			rh.Security = inferSecurity(rh, definitions)
+		}
+
+		result[i] = rh
+	}
+
+	return result
+}
@@ -0,0 +1,196 @@
+package summerfish
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+)
+
+// SetupReport lists what was documented and mounted by Setup
+type SetupReport struct {
+	Routes       []RouteHolder
+	Operations   int
+	SpecFilePath string
+	SpecRoute    string
+	UIRoute      string
+	UIRoutes     []string
+	Diagnostics  Diagnostics
+	Coverage     CoverageReport
+}
+
+// Setup analyzes the router, generates the spec and mounts the routes described by the Config
+func Setup(router *mux.Router, config Config) (report SetupReport, err error) {
+	config = config.withDefaults()
+	result, err := AnalyzeRouter(router, AnalysisOptions{Strict: config.Strict, Overrides: config.Overrides})
+	if err != nil {
+		return
+	}
+
+	routes := result.Routes
+
+	scheme := SchemeHolder{
+		Schemes:             config.Schemes,
+		Host:                config.Host,
+		BasePath:            config.BaseRoute,
+		Information:         config.Information,
+		SecurityDefinitions: config.SecurityDefinitions,
+		Security:            config.Security,
+		Overrides:           config.Overrides,
+	}
+
+	scheme.Build(routes)
+	if len(config.LearnedSchemas) > 0 {
+		var learned LearnedSchemas
+		learned, err = LoadLearnedSchemas(config.LearnedSchemas)
+		if err != nil {
+			return
+		}
+
+		learned.Apply(&scheme)
+	}
+
+	var exampleDiagnostics Diagnostics
+	if config.Examples {
+		exampleDiagnostics = scheme.AddExamples(0)
+	}
+
+	report = SetupReport{Routes: routes, Diagnostics: append(result.Diagnostics, exampleDiagnostics...)}
+	report.Coverage = Coverage(&scheme, result.Diagnostics)
+	if config.Coverage {
+		scheme.AddCoverage(report.Coverage)
+	}
+
+	for _, path := range config.Overlays {
+		var overlay Overlay
+		overlay, err = LoadOverlay(path)
+		if err != nil {
+			return
+		}
+
+		var diagnostics Diagnostics
+		diagnostics, err = scheme.ApplyOverlays(overlay)
+		if err != nil {
+			return
+		}
+
+		report.Diagnostics = append(report.Diagnostics, diagnostics...)
+	}
+
+	for _, methods := range scheme.Paths {
+		report.Operations += len(methods)
+	}
+
+	//writing the file is optional since the spec is served from memory
+	if len(config.SwaggerFilePath) > 0 {
+		report.SpecFilePath, err = filepath.Abs(config.SwaggerFilePath)
+		if err != nil {
+			return
+		}
+
+		//the document is already built, marshaling it directly keeps what was added after Build
+		var encoded []byte
+		if strings.HasSuffix(report.SpecFilePath, ".json") {
+			encoded, err = json.MarshalIndent(&scheme, "", "  ")
+		} else {
+			encoded, err = yaml.Marshal(&scheme)
+		}
+
+		if err != nil {
+			return
+		}
+
+		err = createSwaggerFile(report.SpecFilePath, encoded)
+		if err != nil {
+			return
+		}
+	}
+
+	if config.Validation != nil {
+		router.Use(ValidationMiddleware(&scheme, *config.Validation))
+	}
+
+	if config.Learner != nil {
+		router.Use(config.Learner.Middleware)
+	}
+
+	if len(config.SwaggerFileRoute) > 0 {
+		var specHandler http.Handler
+		specHandler, err = SpecHandler(&scheme)
+		if err != nil {
+			return
+		}
+
+		router.Handle(config.SwaggerFileRoute, specHandler).Methods(http.MethodGet, http.MethodHead)
+		report.SpecRoute = config.SwaggerFileRoute
+	}
+
+	uiRoutes := map[string]UIRenderer{}
+	for route, renderer := range config.UIRoutes {
+		uiRoutes[withTrailingSlash(route)] = renderer
+	}
+
+	if len(config.SwaggerUIRoute) > 0 {
+		uiRoutes[config.SwaggerUIRoute] = SwaggerUI{Title: config.Information.Title}
+		report.UIRoute = config.SwaggerUIRoute
+	}
+
+	for route := range uiRoutes {
+		report.UIRoutes = append(report.UIRoutes, route)
+	}
+
+	//longer prefixes are mounted first so that nested ui routes are not shadowed
+	sort.Slice(report.UIRoutes, func(i, j int) bool {
+		if len(report.UIRoutes[i]) != len(report.UIRoutes[j]) {
+			return len(report.UIRoutes[i]) > len(report.UIRoutes[j])
+		}
+
+		return report.UIRoutes[i] < report.UIRoutes[j]
+	})
+
+	for _, route := range report.UIRoutes {
+		var uiHandler http.Handler
+		uiHandler, err = uiRoutes[route].Handler(config.SwaggerFileHeaderRoute)
+		if err != nil {
+			return
+		}
+
+		router.PathPrefix(route).Handler(http.StripPrefix(route, uiHandler))
+	}
+
+	return
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.BaseRoute) == 0 {
+		c.BaseRoute = "/"
+	}
+
+	if len(c.SwaggerUIRoute) > 0 {
+		c.SwaggerUIRoute = withTrailingSlash(c.SwaggerUIRoute)
+	}
+
+	if len(c.SwaggerFileRoute) == 0 && len(c.SwaggerUIRoute) > 0 {
+		c.SwaggerFileRoute = c.SwaggerUIRoute + "swagger.json"
+	} else if len(c.SwaggerFileRoute) == 0 && len(c.UIRoutes) > 0 {
+		c.SwaggerFileRoute = "/swagger.json"
+	}
+
+	if len(c.SwaggerFileHeaderRoute) == 0 {
+		c.SwaggerFileHeaderRoute = c.SwaggerFileRoute
+	}
+
+	return c
+}
+
+func withTrailingSlash(route string) string {
+	if strings.HasSuffix(route, "/") {
+		return route
+	}
+
+	return route + "/"
+}
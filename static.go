@@ -0,0 +1,596 @@
+package summerfish
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type sourcePackage struct {
+	importPath string
+	fileSet    *token.FileSet
+	files      []*ast.File
+	fileNames  []string
+	functions  map[string]*ast.FuncDecl
+	methods    map[string]*ast.FuncDecl
+}
+
+type staticRouter struct {
+	prefix      string
+	host        string
+	schemes     []string
+	subrouters  []SubrouterInfo
+	middlewares []string
+}
+
+type staticRoute struct {
+	staticRouter
+	path    string
+	name    string
+	methods []string
+	queries []string
+	headers []NameType
+	handler ast.Expr
+}
+
+type chainCall struct {
+	name string
+	args []ast.Expr
+}
+
+type sourceAnalyzer struct {
+	pkg          *sourcePackage
+	packages     map[string]*sourcePackage
+	routers      map[string]staticRouter
+	routeParsers []RouteParser
+	diagnostics  []Diagnostic
+	ID           int
+}
+
+// GetInfoFromSource finds the gorilla mux routes registered in the package at dir without running it.
+// Routers are followed through variables, subrouters and functions receiving them as parameters.
+func GetInfoFromSource(dir string) (holders []RouteHolder, err error) {
+	result, err := AnalyzeSource(dir, AnalysisOptions{})
+	return result.Routes, err
+}
+
+func newSourceAnalyzer(dir string) (analyzer *sourceAnalyzer, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+
+	pkg, err := loadSourcePackage(dir, getImportPath(dir))
+	if err != nil {
+		return
+	}
+
+	analyzer = &sourceAnalyzer{
+		pkg:      pkg,
+		packages: map[string]*sourcePackage{pkg.importPath: pkg},
+		routers:  map[string]staticRouter{},
+	}
+
+	//the first pass discovers the routers passed to functions declared before their callers
+	analyzer.walkPackage(false)
+	analyzer.walkPackage(true)
+	return
+}
+
+func loadSourcePackage(dir, importPath string) (pkg *sourcePackage, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	pkg = &sourcePackage{
+		importPath: importPath,
+		fileSet:    token.NewFileSet(),
+		functions:  map[string]*ast.FuncDecl{},
+		methods:    map[string]*ast.FuncDecl{},
+	}
+
+	packageName := ""
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".go") || strings.HasSuffix(file.Name(), "_test.go") {
+			continue
+		}
+
+		fileName := filepath.Join(dir, file.Name())
+		var parsed *ast.File
+		parsed, err = parser.ParseFile(pkg.fileSet, fileName, nil, 0)
+		if err != nil {
+			return
+		}
+
+		if len(packageName) == 0 {
+			packageName = parsed.Name.Name
+		} else if parsed.Name.Name != packageName {
+			continue
+		}
+
+		pkg.files = append(pkg.files, parsed)
+		pkg.fileNames = append(pkg.fileNames, fileName)
+		for _, decl := range parsed.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+
+			if funcDecl.Recv == nil {
+				pkg.functions[funcDecl.Name.Name] = funcDecl
+			} else if _, exists := pkg.methods[funcDecl.Name.Name]; !exists {
+				pkg.methods[funcDecl.Name.Name] = funcDecl
+			}
+		}
+	}
+
+	return
+}
+
+// getImportPath uses the closest go.mod to name the package, falling back to the GOPATH layout
+func getImportPath(dir string) string {
+	for current := dir; ; current = filepath.Dir(current) {
+		content, err := ioutil.ReadFile(filepath.Join(current, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				line = strings.TrimSpace(line)
+				if !strings.HasPrefix(line, "module ") {
+					continue
+				}
+
+				modulePath := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), "\"")
+				relative, err := filepath.Rel(current, dir)
+				if err != nil || relative == "." {
+					return modulePath
+				}
+
+				return modulePath + "/" + filepath.ToSlash(relative)
+			}
+		}
+
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	goPath := os.Getenv("GOPATH")
+	if len(goPath) == 0 {
+		goPath = build.Default.GOPATH
+	}
+
+	relative, err := filepath.Rel(filepath.Join(goPath, "src"), dir)
+	if err == nil && !strings.HasPrefix(relative, "..") {
+		return filepath.ToSlash(relative)
+	}
+
+	return filepath.Base(dir)
+}
+
+func (a *sourceAnalyzer) walkPackage(emitRoutes bool) {
+	a.ID = 0
+	a.routeParsers = nil
+	a.diagnostics = nil
+	for i, file := range a.pkg.files {
+		for _, decl := range file.Decls {
+			scope := ""
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				scope = funcDecl.Name.Name
+			}
+
+			ast.Inspect(decl, func(node ast.Node) bool {
+				switch statement := node.(type) {
+				case *ast.AssignStmt:
+					if len(statement.Lhs) == 1 && len(statement.Rhs) == 1 {
+						a.processStatement(file, a.pkg.fileNames[i], scope, statement.Lhs[0], statement.Rhs[0], emitRoutes)
+					}
+				case *ast.ValueSpec:
+					if len(statement.Names) == 1 && len(statement.Values) == 1 {
+						a.processStatement(file, a.pkg.fileNames[i], scope, statement.Names[0], statement.Values[0], emitRoutes)
+					}
+				case *ast.ExprStmt:
+					a.processStatement(file, a.pkg.fileNames[i], scope, nil, statement.X, emitRoutes)
+				}
+
+				return true
+			})
+		}
+	}
+}
+
+func (a *sourceAnalyzer) processStatement(file *ast.File, fileName, scope string, lhs, rhs ast.Expr, emitRoutes bool) {
+	base, calls := flattenCallChain(rhs)
+	if len(calls) == 0 {
+		return
+	}
+
+	router, isRouter := a.lookupRouter(scope, base)
+	if !isRouter && calls[0].name != "NewRouter" {
+		if isImportName(file, base) || !isRouterMethod(calls[0].name) {
+			a.bindRouterParameters(scope, base, calls)
+			return
+		}
+	}
+
+	route := staticRoute{staticRouter: router}
+	isRouter = false
+	for _, call := range calls {
+		switch call.name {
+		case "NewRouter":
+			route = staticRoute{}
+			isRouter = true
+		case "HandleFunc", "Handle":
+			route.path = route.prefix + stringArgument(call.args, 0)
+			if len(call.args) > 1 {
+				route.handler = call.args[1]
+			}
+		case "Path", "PathPrefix":
+			route.path = route.prefix + stringArgument(call.args, 0)
+		case "HandlerFunc", "Handler":
+			if len(call.args) > 0 {
+				route.handler = call.args[0]
+			}
+		case "Methods":
+			for i := range call.args {
+				route.methods = append(route.methods, methodArgument(call.args, i))
+			}
+		case "Queries":
+			for i := 0; i+1 < len(call.args); i += 2 {
+				route.queries = append(route.queries, stringArgument(call.args, i)+"="+stringArgument(call.args, i+1))
+			}
+		case "Headers", "HeadersRegexp":
+			for i := 0; i+1 < len(call.args); i += 2 {
+				header := NameType{Name: http.CanonicalHeaderKey(stringArgument(call.args, i)), Type: "string", IsRequired: true}
+				value := stringArgument(call.args, i+1)
+				if call.name == "HeadersRegexp" {
+					header.Pattern = value
+				} else if len(value) > 0 {
+					header.Enum = []string{value}
+				}
+
+				route.headers = append(route.headers, header)
+			}
+		case "Host":
+			route.host = stringArgument(call.args, 0)
+		case "Schemes":
+			for i := range call.args {
+				route.schemes = append(route.schemes, strings.ToLower(stringArgument(call.args, i)))
+			}
+		case "Name":
+			route.name = stringArgument(call.args, 0)
+		case "Use":
+			for _, arg := range call.args {
+				if name := a.middlewareName(file, arg); len(name) > 0 && !containsString(route.middlewares, name) {
+					route.middlewares = append(append([]string{}, route.middlewares...), name)
+				}
+			}
+		case "Subrouter":
+			isRouter = true
+			route.subrouters = append(append([]SubrouterInfo{}, route.subrouters...), SubrouterInfo{Name: route.name, Prefix: route.path})
+			route.prefix = route.path
+		}
+	}
+
+	if ident, ok := lhs.(*ast.Ident); ok && isRouter && route.handler == nil {
+		a.routers[scope+"."+ident.Name] = route.staticRouter
+		return
+	}
+
+	//middlewares are added to the router itself and apply to all of its routes
+	if lhs == nil && calls[len(calls)-1].name == "Use" && len(calls) == 1 {
+		a.routers[a.routerKey(scope, base)] = route.staticRouter
+		return
+	}
+
+	if !emitRoutes || route.handler == nil || len(route.path) == 0 {
+		return
+	}
+
+	a.addRoute(file, fileName, route)
+}
+
+func (a *sourceAnalyzer) addRoute(file *ast.File, fileName string, route staticRoute) {
+	defer func() { a.ID++ }()
+	namePath, endpointPath := a.resolveHandler(a.pkg, file, fileName, route.handler)
+	if namePath.LineNumber == 0 {
+		a.diagnostics = append(a.diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Kind:     DiagnosticHandler,
+			Route:    route.path,
+			Methods:  route.methods,
+			File:     fileName,
+			Line:     a.pkg.fileSet.Position(route.handler.Pos()).Line,
+			Reason:   fmt.Sprintf("source of handler %s could not be found", types.ExprString(route.handler)),
+		})
+		return
+	}
+
+	sort.Slice(route.headers, func(i, j int) bool {
+		return route.headers[i].Name < route.headers[j].Name
+	})
+
+	rp := RouteParser{
+		ID:           a.ID,
+		Route:        route.path,
+		RelativePath: namePath.RelativePath,
+		FullPath:     namePath.FullPath,
+		LineNumber:   namePath.LineNumber,
+		Methods:      route.methods,
+		Queries:      getQueriesFromTemplates(route.queries),
+		Headers:      route.headers,
+		Host:         route.host,
+		Schemes:      route.schemes,
+		Subrouters:   route.subrouters,
+		Middlewares:  route.middlewares,
+	}
+	a.routeParsers = append(a.routeParsers, rp)
+	if endpointPath.LineNumber == 0 {
+		return
+	}
+
+	rp.RelativePath = endpointPath.RelativePath
+	rp.FullPath = endpointPath.FullPath
+	rp.LineNumber = endpointPath.LineNumber
+	rp.IsOnlyEndpointParser = true
+	a.routeParsers = append(a.routeParsers, rp)
+}
+
+func (a *sourceAnalyzer) lookupRouter(scope, name string) (router staticRouter, ok bool) {
+	router, ok = a.routers[a.routerKey(scope, name)]
+	return
+}
+
+// routerKey prefers the router declared in the function over the one declared in the package
+func (a *sourceAnalyzer) routerKey(scope, name string) string {
+	if _, ok := a.routers[scope+"."+name]; ok {
+		return scope + "." + name
+	}
+
+	if _, ok := a.routers["."+name]; ok {
+		return "." + name
+	}
+
+	return scope + "." + name
+}
+
+// middlewareName names a middleware like the runtime does, closures returned by a factory are named factory.func1
+func (a *sourceAnalyzer) middlewareName(file *ast.File, expr ast.Expr) string {
+	switch middleware := expr.(type) {
+	case *ast.Ident:
+		return a.pkg.importPath + "." + middleware.Name
+	case *ast.SelectorExpr:
+		if ident, ok := middleware.X.(*ast.Ident); ok && isImportName(file, ident.Name) {
+			return getImportPathForName(file, ident.Name) + "." + middleware.Sel.Name
+		}
+	case *ast.CallExpr:
+		if name := a.middlewareName(file, middleware.Fun); len(name) > 0 {
+			return name + ".func1"
+		}
+	}
+
+	return ""
+}
+
+// bindRouterParameters records the routers passed to functions of the package, e.g. registerRoutes(api)
+func (a *sourceAnalyzer) bindRouterParameters(scope, base string, calls []chainCall) {
+	if len(base) > 0 || len(calls) != 1 {
+		return
+	}
+
+	funcDecl, ok := a.pkg.functions[calls[0].name]
+	if !ok {
+		return
+	}
+
+	var parameters []string
+	for _, field := range funcDecl.Type.Params.List {
+		for _, name := range field.Names {
+			parameters = append(parameters, name.Name)
+		}
+	}
+
+	for i, arg := range calls[0].args {
+		ident, ok := arg.(*ast.Ident)
+		if !ok || i >= len(parameters) {
+			continue
+		}
+
+		if router, ok := a.lookupRouter(scope, ident.Name); ok {
+			a.routers[funcDecl.Name.Name+"."+parameters[i]] = router
+		}
+	}
+}
+
+// resolveHandler finds the source of the handler and, for go-kit servers, of the endpoint
+func (a *sourceAnalyzer) resolveHandler(pkg *sourcePackage, file *ast.File, fileName string, expr ast.Expr) (RoutePath, RoutePath) {
+	switch handler := expr.(type) {
+	case *ast.Ident:
+		return a.routePathForFunction(pkg, pkg.functions[handler.Name]), RoutePath{}
+	case *ast.FuncLit:
+		return RoutePath{
+			RelativePath: pkg.importPath + ".func",
+			FullPath:     fileName,
+			LineNumber:   pkg.fileSet.Position(handler.Pos()).Line,
+		}, RoutePath{}
+	case *ast.SelectorExpr:
+		ident, ok := handler.X.(*ast.Ident)
+		if ok && isImportName(file, ident.Name) {
+			importedPkg := a.loadImport(file, ident.Name)
+			if importedPkg == nil {
+				return RoutePath{}, RoutePath{}
+			}
+
+			return a.routePathForFunction(importedPkg, importedPkg.functions[handler.Sel.Name]), RoutePath{}
+		}
+
+		return a.routePathForFunction(pkg, pkg.methods[handler.Sel.Name]), RoutePath{}
+	case *ast.CallExpr:
+		_, calls := flattenCallChain(handler)
+		if len(calls) == 0 {
+			return RoutePath{}, RoutePath{}
+		}
+
+		switch calls[len(calls)-1].name {
+		case "HandlerFunc":
+			if len(handler.Args) > 0 {
+				return a.resolveHandler(pkg, file, fileName, handler.Args[0])
+			}
+		case "NewServer":
+			if len(handler.Args) > 1 {
+				decoder, _ := a.resolveHandler(pkg, file, fileName, handler.Args[1])
+				endpoint, _ := a.resolveHandler(pkg, file, fileName, handler.Args[0])
+				return decoder, endpoint
+			}
+		}
+
+		return a.resolveHandler(pkg, file, fileName, handler.Fun)
+	}
+
+	return RoutePath{}, RoutePath{}
+}
+
+func (a *sourceAnalyzer) routePathForFunction(pkg *sourcePackage, funcDecl *ast.FuncDecl) (rp RoutePath) {
+	if funcDecl == nil {
+		return
+	}
+
+	position := pkg.fileSet.Position(funcDecl.Pos())
+	rp.RelativePath = pkg.importPath + "." + funcDecl.Name.Name
+	rp.FullPath = position.Filename
+	rp.LineNumber = position.Line
+	return
+}
+
+func (a *sourceAnalyzer) loadImport(file *ast.File, name string) *sourcePackage {
+	importPath := getImportPathForName(file, name)
+	pkg, ok := a.packages[importPath]
+	if ok {
+		return pkg
+	}
+
+	//failed imports are cached as nil so they are not retried
+	a.packages[importPath] = nil
+	buildPkg, err := build.Import(importPath, filepath.Dir(a.pkg.fileNames[0]), build.FindOnly)
+	if err != nil {
+		return nil
+	}
+
+	pkg, err = loadSourcePackage(buildPkg.Dir, importPath)
+	if err != nil {
+		return nil
+	}
+
+	a.packages[importPath] = pkg
+	return pkg
+}
+
+// flattenCallChain turns router.HandleFunc("/", h).Methods("GET") into the router identifier and its calls
+func flattenCallChain(expr ast.Expr) (base string, calls []chainCall) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		if ident, ok := expr.(*ast.Ident); ok {
+			base = ident.Name
+		}
+
+		return
+	}
+
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return "", []chainCall{{name: fun.Name, args: call.Args}}
+	case *ast.SelectorExpr:
+		base, calls = flattenCallChain(fun.X)
+		if len(calls) == 0 && len(base) == 0 {
+			return
+		}
+
+		calls = append(calls, chainCall{name: fun.Sel.Name, args: call.Args})
+	}
+
+	return
+}
+
+func isRouterMethod(name string) bool {
+	switch name {
+	case "HandleFunc", "Handle", "Path", "PathPrefix", "Methods", "Host", "Schemes", "Headers", "HeadersRegexp", "Queries", "NewRoute":
+		return true
+	}
+
+	return false
+}
+
+func isImportName(file *ast.File, name string) bool {
+	return len(getImportPathForName(file, name)) > 0
+}
+
+func getImportPathForName(file *ast.File, name string) string {
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		importName := spec.Name.String()
+		if spec.Name == nil {
+			importName = getDefaultImportName(importPath)
+		}
+
+		if importName == name {
+			return importPath
+		}
+	}
+
+	return ""
+}
+
+// getDefaultImportName guesses the package name from the usual conventions: yaml.v2, go-kit, /v2 suffixes
+func getDefaultImportName(importPath string) string {
+	split := strings.Split(importPath, "/")
+	name := split[len(split)-1]
+	if len(split) > 1 && versionRegex.FindString(name) == name {
+		name = split[len(split)-2]
+	}
+
+	if index := strings.Index(name, ".v"); index > 0 {
+		name = name[:index]
+	}
+
+	return strings.Replace(strings.TrimPrefix(name, "go-"), "-", "", -1)
+}
+
+// methodArgument accepts both "GET" and http.MethodGet
+func methodArgument(args []ast.Expr, index int) string {
+	if selector, ok := args[index].(*ast.SelectorExpr); ok && strings.HasPrefix(selector.Sel.Name, "Method") {
+		return strings.ToUpper(strings.TrimPrefix(selector.Sel.Name, "Method"))
+	}
+
+	return strings.ToUpper(stringArgument(args, index))
+}
+
+func stringArgument(args []ast.Expr, index int) string {
+	if index >= len(args) {
+		return ""
+	}
+
+	literal, ok := args[index].(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return ""
+	}
+
+	value, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return ""
+	}
+
+	return value
+}
@@ -0,0 +1,88 @@
+package summerfish
+
+import (
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// SubrouterInfo describes one of the PathPrefix(...).Subrouter() routes a route is nested in
+type SubrouterInfo struct {
+	Name   string
+	Prefix string
+}
+
+// RouteGroup holds the routes documented under the same subrouter, so that each one can be emitted as its own spec
+type RouteGroup struct {
+	Name     string
+	BasePath string
+	Routes   []RouteHolder
+}
+
+func getSubroutersFromAncestors(ancestors []*mux.Route) (subrouters []SubrouterInfo) {
+	for _, ancestor := range ancestors {
+		prefix, err := ancestor.GetPathTemplate()
+		if err != nil {
+			prefix = ""
+		}
+
+		//names equal to the template were set by the walker and not by the user
+		name := ancestor.GetName()
+		if name == prefix {
+			name = ""
+		}
+
+		subrouters = append(subrouters, SubrouterInfo{Name: name, Prefix: prefix})
+	}
+
+	return
+}
+
+// getTagFromSubrouters uses the name of the closest subrouter or the last meaningful segment of its prefix
+func getTagFromSubrouters(subrouters []SubrouterInfo) string {
+	for i := len(subrouters) - 1; i >= 0; i-- {
+		if len(subrouters[i].Name) > 0 {
+			return subrouters[i].Name
+		}
+
+		split := strings.Split(normalizeTemplate(subrouters[i].Prefix), "/")
+		for j := len(split) - 1; j >= 0; j-- {
+			segment := split[j]
+			if len(segment) == 0 || strings.HasPrefix(segment, "{") || versionRegex.FindString(segment) == segment {
+				continue
+			}
+
+			return segment
+		}
+	}
+
+	return ""
+}
+
+// GroupRoutesBySubrouter splits the routes by the subrouter found at the given depth, 1 being the outermost one.
+// Routes that are not nested that deep are returned in a group with an empty name and "/" as base path.
+func GroupRoutesBySubrouter(holders []RouteHolder, depth int) (groups []RouteGroup) {
+	indexes := map[string]int{}
+	for _, holder := range holders {
+		group := RouteGroup{BasePath: "/"}
+		if depth > 0 && len(holder.Subrouters) >= depth {
+			subrouter := holder.Subrouters[depth-1]
+			group.BasePath = normalizeTemplate(subrouter.Prefix)
+			group.Name = subrouter.Name
+			if len(group.Name) == 0 {
+				group.Name = strings.Trim(group.BasePath, "/")
+			}
+		}
+
+		index, ok := indexes[group.BasePath]
+		if !ok {
+			index = len(groups)
+			indexes[group.BasePath] = index
+			groups = append(groups, group)
+		}
+
+		groups[index].Routes = append(groups[index].Routes, holder)
+	}
+
+	return
+}
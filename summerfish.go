@@ -3,6 +3,7 @@ package summerfish
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"os"
 	"sort"
 	"strings"
@@ -14,13 +15,37 @@ import (
 type Method map[string]Operation
 type PathsHolder map[string]Method
 
+// Config describes what Setup generates and mounts.
+// SwaggerFilePath is optional, the spec is served from memory at SwaggerFileRoute.
+// SwaggerFileHeaderRoute is the url the UI uses to fetch the spec (defaults to SwaggerFileRoute, useful behind proxies).
+// SwaggerUIRoute serves the embedded Swagger UI and UIRoutes mounts other viewers, e.g. {"/redoc/": summerfish.ReDoc{}}.
+// Strict makes Setup fail when a route or type could not be resolved instead of only reporting it.
+// Coverage adds the x-summerfish-coverage extension with the score of the inferred documentation.
+// Overlays are yaml or json files merged in order on top of the generated spec, see Overlay.
+// SecurityDefinitions are assigned to the operations whose handler or middlewares use them, Security applies to all.
+// Validation adds a middleware to the router rejecting the requests that don't match the spec, see ValidationMiddleware.
+// Learner is added to the router to sample the traffic, and the schemas saved in LearnedSchemas fill the free-form ones.
+// Examples generates the missing examples of the parameters, bodies and responses, see AddExamples.
 type Config struct {
 	Schemes                []string
 	SwaggerFilePath        string
 	SwaggerFileRoute       string
 	SwaggerFileHeaderRoute string
 	SwaggerUIRoute         string
+	UIRoutes               map[string]UIRenderer
 	BaseRoute              string
+	Host                   string
+	Information            SchemeInformation
+	Strict                 bool
+	Coverage               bool
+	Overlays               []string
+	SecurityDefinitions    map[string]SecurityScheme
+	Security               []SecurityRequirement
+	Validation             *ValidationOptions
+	Learner                *SchemaLearner
+	LearnedSchemas         string
+	Examples               bool
+	Overrides              *Overrides
 }
 
 type InputParameter struct {
@@ -30,30 +55,51 @@ type InputParameter struct {
 	QueryType   string           `json:"in" yaml:"in"`
 	Schema      SchemaParameters `json:"schema,omitempty" yaml:"schema,omitempty"`
 	Required    bool             `json:"required,omitempty" yaml:"required,omitempty"`
+	Pattern     string           `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Enum        []string         `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Example     interface{}      `json:"x-example,omitempty" yaml:"x-example,omitempty"`
 }
 
 type OperationResponse struct {
-	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Schema      *SchemaParameters      `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Examples    map[string]interface{} `json:"examples,omitempty" yaml:"examples,omitempty"`
 }
 
 type Operation struct {
-	Parameters []InputParameter             `json:"parameters"`
-	ID         string                       `json:"operationId" yaml:"operationId"`
-	Summary    string                       `json:"summary"`
-	Tags       []string                     `json:"tags"`
-	Responses  map[string]OperationResponse `json:"responses"`
-	Consumes   []string                     `json:"consumes,omitempty" yaml:"consumes,omitempty"`
+	Parameters  []InputParameter             `json:"parameters"`
+	ID          string                       `json:"operationId" yaml:"operationId"`
+	Summary     string                       `json:"summary"`
+	Description string                       `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string                     `json:"tags"`
+	Responses   map[string]OperationResponse `json:"responses"`
+	Consumes    []string                     `json:"consumes,omitempty" yaml:"consumes,omitempty"`
+	Schemes     []string                     `json:"schemes,omitempty" yaml:"schemes,omitempty"`
+	Deprecated  bool                         `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Security    []SecurityRequirement        `json:"security,omitempty" yaml:"security,omitempty"`
+	Coverage    *float64                     `json:"x-summerfish-coverage,omitempty" yaml:"x-summerfish-coverage,omitempty"`
 }
 
 type SchemaParameters struct {
 	Type       string                      `json:"type"`
 	Items      *SchemaParameters           `json:"items,omitempty" yaml:"items,omitempty"`
 	Properties map[string]SchemaParameters `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Enum       []string                    `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Format     string                      `json:"format,omitempty" yaml:"format,omitempty"`
+	Required   []string                    `json:"required,omitempty" yaml:"required,omitempty"`
+	Example    interface{}                 `json:"example,omitempty" yaml:"example,omitempty"`
+	Minimum    *float64                    `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum    *float64                    `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength  *int                        `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength  *int                        `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Pattern    string                      `json:"pattern,omitempty" yaml:"pattern,omitempty"`
 }
 
 type RouteParserHolder struct {
 	routeParsers []RouteParser
 	ID           int
+	Diagnostics  []Diagnostic
+	owners       map[*mux.Route]*mux.Router
 }
 
 type routeHolderAndName struct {
@@ -62,25 +108,30 @@ type routeHolderAndName struct {
 }
 
 func GetInfoFromRouter(r *mux.Router) (holders []RouteHolder, err error) {
-	routeParsers, err := getParsersFromRouter(r)
-	if err != nil {
-		return
-	}
+	result, err := AnalyzeRouter(r, AnalysisOptions{})
+	return result.Routes, err
+}
 
-	sourceFiles, err := generateFileMap(routeParsers)
+func getInfoFromParsers(routeParsers []RouteParser, overrides *Overrides) (result AnalysisResult, err error) {
+	sourceFiles, rawSourceFiles, err := generateFileMap(routeParsers)
 	if err != nil {
 		return
 	}
 
 	routeMap := map[int]routeHolderAndName{}
 	for _, rp := range routeParsers {
+		rp.overrides = overrides
 		var routeHolder RouteHolder
 		if rp.IsOnlyEndpointParser {
 			routeHolder = rp.processSourceFilesForEndpoint(sourceFiles[rp.FullPath])
 		} else {
 			routeHolder = rp.processSourceFiles(sourceFiles[rp.FullPath])
+			routeHolder.addRouteMatchers(rp)
+			routeHolder.addAnnotations(rp.parseAnnotations(rawSourceFiles[rp.FullPath], sourceFiles[rp.FullPath]))
 		}
 
+		result.Diagnostics = append(result.Diagnostics, rp.Diagnostics...)
+
 		wasEndpointParsed := rp.IsOnlyEndpointParser
 		existingRouteHolder, ok := routeMap[rp.ID]
 		if ok {
@@ -100,25 +151,48 @@ func GetInfoFromRouter(r *mux.Router) (holders []RouteHolder, err error) {
 	}
 
 	for _, v := range routeMap {
-		holders = append(holders, v.routeHolder)
+		result.Routes = append(result.Routes, v.routeHolder)
+		if len(v.routeHolder.Methods) == 0 {
+			result.Diagnostics = append(result.Diagnostics, Diagnostic{
+				Severity: SeverityWarning,
+				Kind:     DiagnosticRoute,
+				Route:    v.routeHolder.Route,
+				Reason:   "route has no methods and is not documented",
+			})
+			continue
+		}
+
+		result.Diagnostics = append(result.Diagnostics, unreadPathVariables(v.routeHolder)...)
 	}
 
 	//route holders are sorted so that the endpoints are in the same position in the docs
-	sort.Slice(holders, func(i, j int) bool {
-		return holders[i].ID > holders[j].ID
+	sort.Slice(result.Routes, func(i, j int) bool {
+		return result.Routes[i].ID > result.Routes[j].ID
 	})
 
 	return
 }
 
-func getParsersFromRouter(r *mux.Router) (routeParsers []RouteParser, err error) {
-	holder := RouteParserHolder{}
-	err = r.Walk(holder.walkGorillaMuxRoutes)
-	if err != nil {
-		return
+// unreadPathVariables reports the variables of the template without a pattern that the handler doesn't read,
+// their type is only assumed to be a string
+func unreadPathVariables(rh RouteHolder) (diagnostics Diagnostics) {
+	for _, variable := range parseTemplateVariables(rh.Route) {
+		read := len(variable.Pattern) > 0
+		for _, entry := range rh.Path {
+			read = read || entry.Name == variable.Name
+		}
+
+		if !read {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityInfo,
+				Kind:     DiagnosticParameter,
+				Route:    rh.Route,
+				Methods:  rh.Methods,
+				Reason:   fmt.Sprintf("path variable %s is not read by the handler and has no pattern, its type defaults to string", variable.Name),
+			})
+		}
 	}
 
-	routeParsers = holder.routeParsers
 	return
 }
 
@@ -145,13 +219,53 @@ func (rph *RouteParserHolder) walkGorillaMuxRoutes(route *mux.Route, router *mux
 		err = nil
 	}
 
-	handler := route.Name(pathTemplate).GetHandler()
+	queries, err := getQueriesFromRoute(route)
+	if err != nil {
+		return
+	}
+
+	host, err := getHostFromRoute(route)
+	if err != nil {
+		return
+	}
+
+	headers, schemes := getMatchersFromRoute(route)
+	subrouters := getSubroutersFromAncestors(ancestors)
+
+	//the routers a route is nested in are the ones walked for its ancestors
+	if rph.owners == nil {
+		rph.owners = map[*mux.Route]*mux.Router{}
+	}
+
+	rph.owners[route] = router
+	var routers []*mux.Router
+	for _, ancestor := range ancestors {
+		routers = append(routers, rph.owners[ancestor])
+	}
+
+	middlewares := getMiddlewaresFromRouters(append(routers, router))
+	if len(route.GetName()) == 0 {
+		route.Name(pathTemplate)
+	}
+
+	handler := route.GetHandler()
 	if handler == nil {
+		if !hasSubrouter(route) {
+			rph.Diagnostics = append(rph.Diagnostics, Diagnostic{Severity: SeverityWarning, Kind: DiagnosticRoute, Route: pathTemplate, Methods: methods, Reason: "route has no handler"})
+		}
+
 		return
 	}
 
 	namePath, endpointPath := processHandler(handler)
 	if namePath.LineNumber == 0 {
+		rph.Diagnostics = append(rph.Diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Kind:     DiagnosticHandler,
+			Route:    pathTemplate,
+			Methods:  methods,
+			Reason:   fmt.Sprintf("source of handler %s could not be found", getHandlerName(handler, namePath)),
+		})
 		return
 	}
 
@@ -163,6 +277,12 @@ func (rph *RouteParserHolder) walkGorillaMuxRoutes(route *mux.Route, router *mux
 		LineNumber:           namePath.LineNumber,
 		Methods:              methods,
 		IsOnlyEndpointParser: false,
+		Queries:              queries,
+		Headers:              headers,
+		Host:                 host,
+		Schemes:              schemes,
+		Subrouters:           subrouters,
+		Middlewares:          middlewares,
 	})
 
 	if endpointPath.LineNumber == 0 {
@@ -177,30 +297,38 @@ func (rph *RouteParserHolder) walkGorillaMuxRoutes(route *mux.Route, router *mux
 		LineNumber:           endpointPath.LineNumber,
 		Methods:              methods,
 		IsOnlyEndpointParser: true,
+		Queries:              queries,
+		Headers:              headers,
+		Host:                 host,
+		Schemes:              schemes,
+		Subrouters:           subrouters,
 	})
 	return
 }
 
-func generateFileMap(routeParsers []RouteParser) (sourceFiles map[string][]string, err error) {
+// generateFileMap reads the source files of the parsers, without comments and as they are for the annotations
+func generateFileMap(routeParsers []RouteParser) (sourceFiles, rawSourceFiles map[string][]string, err error) {
 	sourceFiles = make(map[string][]string)
+	rawSourceFiles = make(map[string][]string)
 	for _, rp := range routeParsers {
 		_, wasProcessed := sourceFiles[rp.FullPath]
 		if wasProcessed {
 			continue
 		}
 
-		var lines []string
-		lines, err = processRouteParserSourceFile(rp.FullPath)
+		var lines, rawLines []string
+		lines, rawLines, err = processRouteParserSourceFile(rp.FullPath)
 		if err != nil {
 			return
 		}
 
 		sourceFiles[rp.FullPath] = lines
+		rawSourceFiles[rp.FullPath] = rawLines
 	}
 	return
 }
 
-func processRouteParserSourceFile(path string) (lines []string, err error) {
+func processRouteParserSourceFile(path string) (lines, rawLines []string, err error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return
@@ -212,6 +340,7 @@ func processRouteParserSourceFile(path string) (lines []string, err error) {
 	var line string
 	for scanner.Scan() {
 		//clean commented lines or sections
+		rawLines = append(rawLines, scanner.Text())
 		line, isCommentSection = cleanCommentSection(scanner.Text(), isCommentSection)
 		lines = append(lines, line)
 	}
@@ -253,10 +382,31 @@ func RemoveCommentSection(line string) (string, bool) {
 	return line, true
 }
 
-func (s *SchemeHolder) GenerateSwaggerJson(routes []RouteHolder, filePath string) (err error) {
+// Build fills the document with the paths generated from the routes
+func (s *SchemeHolder) Build(routes []RouteHolder) {
+	//the fields decoded from the overlays are discarded with them
+	if s.generated != nil {
+		*s = *s.generated
+	}
+
 	s.SwaggerVersion = "2.0"
-	s.Paths = mapRoutesToPaths(routes, s.BasePath)
-	encoded, err := json.MarshalIndent(s, "", "  ")
+	s.extensions = nil
+	s.Paths = mapRoutesToPaths(applySecurity(s.Overrides.apply(routes), s.SecurityDefinitions), s.BasePath, s.Overrides)
+	s.Servers = mapRoutesToServers(routes, s.Schemes, s.BasePath)
+}
+
+func (s *SchemeHolder) MarshalSwaggerJson(routes []RouteHolder) ([]byte, error) {
+	s.Build(routes)
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func (s *SchemeHolder) MarshalSwaggerYaml(routes []RouteHolder) ([]byte, error) {
+	s.Build(routes)
+	return yaml.Marshal(&s)
+}
+
+func (s *SchemeHolder) GenerateSwaggerJson(routes []RouteHolder, filePath string) (err error) {
+	encoded, err := s.MarshalSwaggerJson(routes)
 	if err != nil {
 		return
 	}
@@ -265,9 +415,7 @@ func (s *SchemeHolder) GenerateSwaggerJson(routes []RouteHolder, filePath string
 }
 
 func (s *SchemeHolder) GenerateSwaggerYaml(routes []RouteHolder, filePath string) (err error) {
-	s.SwaggerVersion = "2.0"
-	s.Paths = mapRoutesToPaths(routes, s.BasePath)
-	encoded, err := yaml.Marshal(&s)
+	encoded, err := s.MarshalSwaggerYaml(routes)
 	if err != nil {
 		return
 	}
@@ -1,10 +1,26 @@
 package summerfish
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
 )
 
 func TestProcessSourceFiles(t *testing.T) {
@@ -217,6 +233,35 @@ func TestProcessBodyVars(t *testing.T) {
 	}
 }
 
+func TestProcessHeaderConstants(t *testing.T) {
+	source, err := filepath.Abs("testdata/static/routes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routeParser := RouteParser{Route: "/users", RelativePath: "github.com/plicca/summerfish-swagger/testdata/static.Handler", FullPath: source, LineNumber: 4}
+	result := routeParser.processSourceFiles([]string{
+		"import (",
+		"	\"github.com/plicca/summerfish-swagger/testdata/static\"",
+		")",
+		"func Handler(w http.ResponseWriter, r *http.Request) {",
+		"	tenant := r.Header.Get(\"X-Tenant\")",
+		"	requestID := r.Header.Get(static.RequestIDHeader)",
+		"	sameID := r.Header.Get(RequestIDHeader)",
+		"	token := r.Header.Get(strings.ToLower(name))",
+		"}",
+	})
+
+	if strings.Join(result.RequestHeaders, ",") != "X-Tenant,X-Request-Id,X-Request-Id" {
+		t.Fatal(result.RequestHeaders)
+	}
+
+	if len(routeParser.Diagnostics) != 1 || routeParser.Diagnostics[0].Kind != DiagnosticParameter ||
+		!strings.Contains(routeParser.Diagnostics[0].Reason, "header read with strings.ToLower(name) is not documented") {
+		t.Fatal(routeParser.Diagnostics)
+	}
+}
+
 func TestProcessArrayVars(t *testing.T) {
 	type args struct {
 		lines NameType
@@ -248,7 +293,7 @@ func TestProcessArrayVars(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := mapBodyRoute(tt.args.lines)
+			result := DefaultOverrides.mapBodyRoute(tt.args.lines)
 			okResponse := OperationResponse{Description: "All okay!"}
 			op := Operation{
 				ID:         "Something",
@@ -280,3 +325,1711 @@ func contains(s []NameType, e string) bool {
 	}
 	return false
 }
+
+func TestStructFieldTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summerfish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	source := "package model\n\ntype Account struct {\n" +
+		"\tID       int64      `json:\"id\"`\n" +
+		"\tPassword string     `json:\"-\"`\n" +
+		"\tDash     string     `json:\"-,\"`\n" +
+		"\tEmail    string     `json:\",omitempty\"`\n" +
+		"\tNickname *string    `json:\"nickname\"`\n" +
+		"\tOwner    *Owner     `json:\"owner,omitempty\"`\n" +
+		"\tMembers  []*Owner   `json:\"members\"`\n" +
+		"}\n\ntype Owner struct {\n\tName string `json:\"name\"`\n}\n"
+	path := filepath.Join(dir, "model.go")
+	err = ioutil.WriteFile(path, []byte(source), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rp := RouteParser{}
+	account := rp.searchForStruct("model.Account", "", []string{path}, false)
+	fields := map[string]NameType{}
+	for _, child := range account.Children {
+		fields[child.Name] = child
+	}
+
+	if _, ok := fields["Password"]; ok || len(fields) != 6 || len(rp.Diagnostics) > 0 {
+		t.Fatal(account.Children, rp.Diagnostics)
+	}
+
+	if fields["id"].Optional || !fields["Email"].Optional || !fields["nickname"].Optional || fields["nickname"].Type != "string" {
+		t.Fatal(fields)
+	}
+
+	if owner := fields["owner"]; !owner.Optional || len(owner.Children) != 1 || !fields["members"].IsArray || len(fields["members"].Children) != 1 {
+		t.Fatal(fields)
+	}
+
+	//json:"-," names the field "-"
+	if _, ok := fields["-"]; !ok {
+		t.Fatal(fields)
+	}
+
+	//the spec documents the fields like encoding/json encodes them
+	schema := DefaultOverrides.mapInternalParameters(account)
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"type":"object","properties":{` +
+		`"-":{"type":"string"},` +
+		`"Email":{"type":"string"},` +
+		`"id":{"type":"number"},` +
+		`"members":{"type":"array","items":{"type":"object","properties":{"name":{"type":"string"}}}},` +
+		`"nickname":{"type":"string"},` +
+		`"owner":{"type":"object","properties":{"name":{"type":"string"}}}}}`
+	if string(encoded) != expected {
+		t.Fatal(string(encoded))
+	}
+}
+
+func TestRouteMatchers(t *testing.T) {
+	route := mux.NewRouter().HandleFunc("/list/", dummyHandler).Methods("GET").
+		Queries("page", "{page:[0-9]+}", "kind", "admin").
+		Headers("Content-Type", "application/json").
+		Host("{tenant}.api.example.com").
+		Schemes("https")
+
+	queries, err := getQueriesFromRoute(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 2 || queries[0].Pattern != "^[0-9]+$" || !queries[0].IsRequired || len(queries[1].Enum) != 1 {
+		t.Fatal(queries)
+	}
+
+	headers, schemes := getMatchersFromRoute(route)
+	if len(headers) != 1 || headers[0].Name != contentTypeHeader || headers[0].Enum[0] != "application/json" {
+		t.Fatal(headers)
+	}
+
+	if len(schemes) != 1 || schemes[0] != "https" {
+		t.Fatal(schemes)
+	}
+
+	servers := mapRoutesToServers([]RouteHolder{{Host: "{tenant:[a-z]+}.api.example.com", Schemes: schemes}}, nil, "/")
+	if len(servers) != 1 || servers[0].URL != "https://{tenant}.api.example.com/" || servers[0].Variables["tenant"].Pattern != "^[a-z]+$" {
+		t.Fatal(servers)
+	}
+}
+
+func dummyHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestPathTemplatePrefix(t *testing.T) {
+	routes := []RouteHolder{{Route: "/api/{version:v[0-9]+}/users/{id:[0-9]+}", Methods: []string{"GET"}, Name: "GetUser"}}
+	for _, prefix := range []string{"/api/{version}", "/api/{version:v[0-9]+}/"} {
+		paths := mapRoutesToPaths(routes, prefix, nil)
+		if _, ok := paths["/users/{id}"]["get"]; !ok || len(paths) != 1 {
+			t.Fatal(prefix, paths)
+		}
+	}
+
+	//the version belongs to the base path, even when the handler reads it
+	routes[0].Path = []NameType{{Name: "version", Type: "string"}, {Name: "id", Type: "integer"}}
+	scheme := SchemeHolder{SwaggerVersion: "2.0", BasePath: "/api/{version:v[0-9]+}", Paths: mapRoutesToPaths(routes, "/api/{version:v[0-9]+}", nil)}
+	parameters := scheme.Paths["/users/{id}"]["get"].Parameters
+	if len(parameters) != 1 || parameters[0].Name != "id" || parameters[0].QueryType != "path" {
+		t.Fatal(parameters)
+	}
+
+	issues, err := Lint(&scheme, LintConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, issue := range issues {
+		if issue.Rule == "path-parameters" {
+			t.Fatal(issue)
+		}
+	}
+
+	//the templates matched by the router are trimmed the same way to find their operation
+	scheme.Paths["/users/{id}"]["get"].Parameters[0].Type = "integer"
+	router := mux.NewRouter()
+	router.HandleFunc("/api/{version:v[0-9]+}/users/{id}", dummyHandler).Methods("GET")
+	router.Use(ValidationMiddleware(&scheme, ValidationOptions{Report: func(r *http.Request, problem Problem) {}}))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/users/ann", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestPathTemplateVariables(t *testing.T) {
+	tests := []struct {
+		template   string
+		normalized string
+		types      []string
+	}{
+		{"/users/{id:[0-9]+}", "/users/{id}", []string{"integer"}},
+		{"/users/{id}/items/{slug:[a-z]{3}}", "/users/{id}/items/{slug}", []string{"string", "string"}},
+		{"/years/{year:\\d{4}}/{offset:-?[0-9]+}", "/years/{year}/{offset}", []string{"integer", "integer", "string"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if normalized := normalizeTemplate(tt.template); normalized != tt.normalized {
+				t.Fatal(normalized, tt.normalized)
+			}
+
+			path := mergePathVariables([]NameType{{Name: "id", Type: "string"}}, parseTemplateVariables(tt.template))
+			if len(path) != len(tt.types) {
+				t.Fatal(path)
+			}
+
+			for i, entry := range path {
+				if varType := inferTypeFromPattern(entry.Type, entry.Pattern); varType != tt.types[i] {
+					t.Fatal(entry, varType, tt.types[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSubrouterGrouping(t *testing.T) {
+	holders := []RouteHolder{
+		{Route: "/ping", Subrouters: nil},
+		{Route: "/api/v1/users/{id}", Subrouters: []SubrouterInfo{{Prefix: "/api/v1"}, {Prefix: "/api/v1/users"}}},
+		{Route: "/api/v1/status", Subrouters: []SubrouterInfo{{Prefix: "/api/v1"}}},
+		{Route: "/admin/stats", Subrouters: []SubrouterInfo{{Name: "Backoffice", Prefix: "/admin"}}},
+	}
+
+	tags := []string{"", "users", "api", "Backoffice"}
+	for i, holder := range holders {
+		if tag := getTagFromSubrouters(holder.Subrouters); tag != tags[i] {
+			t.Fatal(holder.Route, tag, tags[i])
+		}
+	}
+
+	groups := GroupRoutesBySubrouter(holders, 1)
+	if len(groups) != 3 || groups[1].BasePath != "/api/v1" || len(groups[1].Routes) != 2 || groups[2].Name != "Backoffice" {
+		t.Fatal(groups)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summerfish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	config := Config{
+		SwaggerFilePath:  filepath.Join(dir, "swagger.json"),
+		SwaggerFileRoute: "/docs/swagger.json",
+		SwaggerUIRoute:   "/docs",
+		UIRoutes:         map[string]UIRenderer{"/docs/redoc": ReDoc{}, "/rapidoc/": RapiDoc{Title: "Internal"}},
+	}
+
+	uiRoute := "/docs/"
+	if !swaggerUIEmbedded() {
+		//without the vendored swagger-ui-dist the default UI fails loudly, the rest is checked with a self hosted copy
+		if _, err = Setup(mux.NewRouter(), config); err == nil || !strings.Contains(err.Error(), "go generate") {
+			t.Fatal(err)
+		}
+
+		config.SwaggerUIRoute, uiRoute = "", ""
+		config.UIRoutes["/docs"] = SwaggerUI{AssetsURL: "/static/swagger-ui"}
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id:[0-9]+}", dummyHandler).Methods("GET")
+	report, err := Setup(router, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Operations != 1 || report.UIRoute != uiRoute || report.SpecRoute != "/docs/swagger.json" || len(report.UIRoutes) != 3 {
+		t.Fatal(report)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/swagger.json", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "/users/{id}") {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if !strings.Contains(recorder.Body.String(), `url: "/docs/swagger.json"`) {
+		t.Fatal(recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/redoc/", nil))
+	if !strings.Contains(recorder.Body.String(), `<redoc spec-url="/docs/swagger.json">`) {
+		t.Fatal(recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/rapidoc/", nil))
+	if !strings.Contains(recorder.Body.String(), "<title>Internal</title>") {
+		t.Fatal(recorder.Body.String())
+	}
+
+	handler, err := SwaggerUI{AssetsURL: "/static/swagger-ui"}.Handler("/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(recorder.Body.String(), "/static/swagger-ui/swagger-ui-bundle.js") || strings.Contains(recorder.Body.String(), "cdnjs") {
+		t.Fatal(recorder.Body.String())
+	}
+}
+
+func TestSwaggerUIAssets(t *testing.T) {
+	if !swaggerUIEmbedded() {
+		t.Skip("swaggerui/dist holds no swagger-ui-dist files, run go generate to vendor them")
+	}
+
+	router := mux.NewRouter()
+	_, err := Setup(router, Config{SwaggerUIRoute: "/docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/swagger-ui-bundle.js", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Header().Get("Content-Type"), "javascript") || recorder.Body.Len() == 0 {
+		t.Fatal(recorder.Code, recorder.Header())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if !strings.Contains(recorder.Body.String(), `src="./swagger-ui-bundle.js"`) {
+		t.Fatal(recorder.Body.String())
+	}
+}
+
+type valueHandler struct{}
+
+func (valueHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func TestDiagnostics(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/users", dummyHandler).Methods("GET")
+	router.Handle("/static", valueHandler{}).Methods("GET")
+	router.Path("/pending").Methods("POST")
+	result, err := AnalyzeRouter(router, AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Routes) != 1 || result.Diagnostics.Count(SeverityError) != 1 || result.Diagnostics.Count(SeverityWarning) != 1 {
+		t.Fatal(result.Diagnostics)
+	}
+
+	if result.Diagnostics[0].String() != "error: GET /static: source of handler summerfish.valueHandler could not be found" {
+		t.Fatal(result.Diagnostics[0])
+	}
+
+	_, err = AnalyzeRouter(router, AnalysisOptions{Strict: true})
+	diagnosticsError, ok := err.(*DiagnosticsError)
+	if !ok || len(diagnosticsError.Diagnostics) != 1 {
+		t.Fatal(err)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	scheme := SchemeHolder{Paths: PathsHolder{
+		"/users": Method{"post": Operation{
+			Summary:    "Create User",
+			Parameters: []InputParameter{{Name: "User", QueryType: "body", Schema: SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{"Address": {}}}}},
+			Responses:  map[string]OperationResponse{"201": {Description: "created"}},
+		}},
+	}}
+
+	diagnostics := Diagnostics{{Severity: SeverityError, Kind: DiagnosticHandler, Route: "/static/{file:.+}", Methods: []string{"GET"}}}
+	report := Coverage(&scheme, diagnostics)
+	if len(report.Operations) != 2 || report.Operations[0].Score != 80 || report.Operations[1].Path != "/static/{file}" || report.Score != 40 {
+		t.Fatal(report)
+	}
+
+	summary := report.Summary()
+	if summary.Checks[CoverageBody] != 0 || summary.Checks[CoverageHandler] != 50 || summary.Operations != 2 {
+		t.Fatal(summary)
+	}
+
+	scheme.AddCoverage(report)
+	if *scheme.Paths["/users"]["post"].Coverage != 80 || scheme.Coverage.Score != 40 {
+		t.Fatal(scheme.Paths)
+	}
+
+	scheme = SchemeHolder{BasePath: "/api/", Paths: PathsHolder{
+		"/users/{id}": Method{
+			"get": Operation{
+				Summary:    "Get User",
+				Parameters: []InputParameter{{Name: "id", QueryType: "path", Type: "string"}},
+				Responses:  map[string]OperationResponse{"200": {Schema: &SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{"id": {Type: "string"}}}}},
+			},
+			"put": Operation{
+				Summary:    "Update User",
+				Parameters: []InputParameter{{Name: "User", QueryType: "body", Schema: SchemaParameters{Type: "object"}}},
+				Responses:  map[string]OperationResponse{"204": {}},
+			},
+		},
+	}}
+
+	diagnostics = Diagnostics{
+		{Severity: SeverityWarning, Kind: DiagnosticType, Route: "/api/users/{id:[0-9]+}", Methods: []string{"get"}},
+		{Severity: SeverityInfo, Kind: DiagnosticParameter, Route: "/api/users/{id}", Methods: []string{"GET"}},
+	}
+	checks := map[string]map[string]bool{}
+	for _, operation := range Coverage(&scheme, diagnostics).Operations {
+		checks[operation.Method] = operation.Checks
+	}
+
+	if checks["GET"][CoverageResponses] || checks["GET"][CoverageParameters] || !checks["GET"][CoverageName] {
+		t.Fatal(checks["GET"])
+	}
+
+	//an object without properties is a struct that wasn't found
+	if checks["PUT"][CoverageBody] || !checks["PUT"][CoverageResponses] || !checks["PUT"][CoverageParameters] {
+		t.Fatal(checks["PUT"])
+	}
+}
+
+type errorBody struct {
+	Message string    `json:"message"`
+	Code    int       `json:"code,omitempty"`
+	At      time.Time `json:"at"`
+	Details []string
+	ignored bool
+}
+
+func TestOverrides(t *testing.T) {
+	defer ClearOverrides()
+	router := mux.NewRouter()
+	router.HandleFunc("/users", dummyHandler).Methods("GET")
+	router.HandleFunc("/users/{id}", dummyHandler).Methods("DELETE")
+	Describe(dummyHandler).Summary("Manage users").Response(http.StatusNotFound, errorBody{})
+	DescribeRoute("delete", "/users/{id:[0-9]+}").OperationID("deleteUser").Response(http.StatusNoContent, nil).
+		Param(InputParameter{Name: "id", QueryType: "path", Type: "integer", Required: true})
+
+	routes, err := GetInfoFromRouter(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := SchemeHolder{BasePath: "/"}
+	scheme.Build(routes)
+	list, remove := scheme.Paths["/users"]["get"], scheme.Paths["/users/{id}"]["delete"]
+	if list.Summary != "Manage users" || len(list.Responses) != 2 || list.Responses["404"].Schema.Properties["message"].Type != "string" {
+		t.Fatal(list)
+	}
+
+	if properties := list.Responses["404"].Schema.Properties; len(properties) != 4 || properties["at"].Type != "string" || properties["Details"].Items.Type != "string" {
+		t.Fatal(properties)
+	}
+
+	if remove.ID != "deleteUser" || len(remove.Responses) != 2 || len(remove.Parameters) != 1 || remove.Parameters[0].Type != "integer" {
+		t.Fatal(remove)
+	}
+
+	OverrideSchema(errorBody{}, SchemaParameters{Type: "string"})
+	if schema := DefaultOverrides.schemaFromType(reflect.TypeOf([]errorBody{}), map[reflect.Type]bool{}); schema.Items.Type != "string" {
+		t.Fatal(schema)
+	}
+}
+
+func TestOverridesRegistry(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/users", dummyHandler).Methods("GET")
+	overrides := NewOverrides()
+	overrides.OverrideSchema(errorBody{}, SchemaParameters{Type: "string"})
+	overrides.DescribeRoute("get", "/users").Summary("List users").Response(http.StatusOK, []errorBody{})
+
+	report, err := Setup(router, Config{Overrides: overrides})
+	if err != nil || report.Operations != 1 {
+		t.Fatal(err, report)
+	}
+
+	//the registry of the config is applied, the default one is left untouched
+	scheme := SchemeHolder{BasePath: "/", Overrides: overrides}
+	scheme.Build(report.Routes)
+	list := scheme.Paths["/users"]["get"]
+	if list.Summary != "List users" || list.Responses["200"].Schema.Items.Type != "string" {
+		t.Fatal(list)
+	}
+
+	if len(DefaultOverrides.operations) != 0 {
+		t.Fatal(DefaultOverrides.operations)
+	}
+
+	scheme = SchemeHolder{BasePath: "/"}
+	scheme.Build(report.Routes)
+	if scheme.Paths["/users"]["get"].Summary == "List users" {
+		t.Fatal(scheme.Paths)
+	}
+
+	source, err := GenerateTypeScript(report.Routes, TypeScriptOptions{Overrides: overrides})
+	if err != nil || !strings.Contains(string(source), "Promise<string[]>") {
+		t.Fatal(err, string(source))
+	}
+}
+
+type category struct {
+	Name     string      `json:"name"`
+	Parent   *category   `json:"parent,omitempty"`
+	Children []*category `json:"children"`
+}
+
+func TestRecursiveResponse(t *testing.T) {
+	defer ClearOverrides()
+	router := mux.NewRouter()
+	router.HandleFunc("/categories", dummyHandler).Methods("GET")
+	Describe(dummyHandler).Response(http.StatusOK, category{})
+
+	routes, err := GetInfoFromRouter(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := SchemeHolder{BasePath: "/"}
+	scheme.Build(routes)
+	properties := scheme.Paths["/categories"]["get"].Responses["200"].Schema.Properties
+	if properties["name"].Type != "string" || properties["parent"].Type != "object" || len(properties["parent"].Properties) != 0 {
+		t.Fatal(properties)
+	}
+
+	if children := properties["children"]; children.Type != "array" || children.Items.Type != "object" || len(children.Items.Properties) != 0 {
+		t.Fatal(children)
+	}
+}
+
+func TestOverlays(t *testing.T) {
+	scheme := SchemeHolder{SwaggerVersion: "2.0", Paths: PathsHolder{
+		"/users": Method{"get": Operation{ID: "ListUsers", Tags: []string{"Users"}, Parameters: []InputParameter{{Name: "page", QueryType: "query"}}}},
+	}}
+
+	actions, err := ParseOverlay("actions.yaml", []byte(`
+overlay: 1.0.0
+actions:
+  - target: $.paths['/users'].get
+    update: {description: Lists the users, tags: [Admin]}
+  - target: $.paths.*.get.parameters[0]
+    remove: true
+  - target: $.paths['/accounts'].get
+    update: {summary: gone}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merge, err := ParseOverlay("merge.yaml", []byte(`
+securityDefinitions: {key: {type: apiKey, in: header, name: X-Key}}
+paths: {/users: {get: {summary: List users, x-internal: true}, post: {summary: gone}}}
+/info/title: Users API
+/paths/~1accounts: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := scheme.ApplyOverlays(actions, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diagnostics) != 3 || diagnostics[0].Reason != "target $.paths['/accounts'].get of action 2 matches nothing" || diagnostics[1].Reason != "operation POST /users does not exist" {
+		t.Fatal(diagnostics)
+	}
+
+	if diagnostics[2].Reason != "path /accounts is added by the overlay and not served by the router" || diagnostics[2].File != "merge.yaml" {
+		t.Fatal(diagnostics[2])
+	}
+
+	//the fields follow the overlays, not only the encoded document
+	users := scheme.Paths["/users"]["get"]
+	if scheme.Information.Title != "Users API" || users.Summary != "List users" || users.Description != "Lists the users" || len(users.Parameters) != 0 || len(scheme.SecurityDefinitions) != 1 {
+		t.Fatal(scheme)
+	}
+
+	if _, ok := scheme.Paths["/accounts"]; !ok {
+		t.Fatal(scheme.Paths)
+	}
+
+	encoded, err := json.Marshal(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{`"title":"Users API"`, `"description":"Lists the users"`, `"tags":["Users","Admin"]`, `"parameters":[]`, `"summary":"List users"`, `"x-internal":true`, `"securityDefinitions"`, `"/accounts":{}`} {
+		if !strings.Contains(string(encoded), expected) {
+			t.Fatal(expected, string(encoded))
+		}
+	}
+
+	//changes made after the overlays are encoded, with the extensions the fields can't hold
+	users.Summary = "Lists every user"
+	scheme.Paths["/users"]["get"] = users
+	scheme.Paths["/teams"] = Method{"get": Operation{ID: "ListTeams"}}
+	delete(scheme.Paths, "/accounts")
+	encoded, _ = json.Marshal(scheme)
+	yamlEncoded, _ := yaml.Marshal(scheme)
+	for _, document := range []string{string(encoded), string(yamlEncoded)} {
+		if !strings.Contains(document, "Lists every user") || !strings.Contains(document, "ListTeams") || !strings.Contains(document, "x-internal") ||
+			strings.Contains(document, "/accounts") {
+			t.Fatal(document)
+		}
+	}
+
+	scheme.Build(nil)
+	encoded, _ = json.Marshal(scheme)
+	if strings.Contains(string(encoded), "securityDefinitions") {
+		t.Fatal(string(encoded))
+	}
+}
+
+func apiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Header.Get("x-api-key")))
+}
+
+func basicAuthHandler(w http.ResponseWriter, r *http.Request) {
+	user, _, _ := r.BasicAuth()
+	w.Write([]byte(user))
+}
+
+func authMiddleware(realm string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Realm", realm)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(mux.CORSMethodMiddleware(router))
+	router.HandleFunc("/public", dummyHandler).Methods("GET")
+	router.HandleFunc("/key", apiKeyHandler).Methods("GET")
+	router.HandleFunc("/basic", basicAuthHandler).Methods("GET")
+	private := router.PathPrefix("/private").Subrouter()
+	private.Use(authMiddleware("private"))
+	private.HandleFunc("/users", dummyHandler).Methods("GET")
+
+	routes, err := GetInfoFromRouter(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := SchemeHolder{BasePath: "/", SecurityDefinitions: map[string]SecurityScheme{
+		"key":    APIKeyHeader("X-API-Key"),
+		"basic":  BasicAuth(),
+		"bearer": BearerJWT().WithMiddleware(authMiddleware("any")),
+	}}
+
+	scheme.Build(routes)
+	expected := map[string]string{"/public": "", "/key": "key", "/basic": "basic", "/private/users": "bearer"}
+	for path, name := range expected {
+		security := scheme.Paths[path]["get"].Security
+		if len(name) == 0 && len(security) == 0 {
+			continue
+		}
+
+		if len(security) != 1 || security[0][name] == nil {
+			t.Fatal(path, security)
+		}
+	}
+
+	encoded, err := json.Marshal(scheme)
+	if err != nil || !strings.Contains(string(encoded), `"securityDefinitions":{"basic":{"type":"basic"}`) {
+		t.Fatal(err, string(encoded))
+	}
+}
+
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func TestMiddlewareEffects(t *testing.T) {
+	defer ClearOverrides()
+	router := mux.NewRouter()
+	router.Use(rateLimitMiddleware)
+	router.HandleFunc("/public", dummyHandler).Methods("GET")
+	private := router.PathPrefix("/private").Subrouter()
+	private.Use(authMiddleware("private"))
+	private.HandleFunc("/users", apiKeyHandler).Methods("GET")
+
+	DescribeMiddleware(rateLimitMiddleware).Response(http.StatusTooManyRequests, nil)
+	DescribeMiddleware(authMiddleware("")).Header("x-request-id", "Request id").Response(http.StatusUnauthorized, errorBody{}).Security("jwt")
+	Describe(apiKeyHandler).Response(http.StatusUnauthorized, nil)
+
+	routes, err := GetInfoFromRouter(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := SchemeHolder{BasePath: "/"}
+	scheme.Build(routes)
+	public, users := scheme.Paths["/public"]["get"], scheme.Paths["/private/users"]["get"]
+	if len(public.Responses) != 2 || len(public.Parameters) != 0 || len(public.Security) != 0 {
+		t.Fatal(public)
+	}
+
+	if len(users.Responses) != 3 || users.Responses["401"].Schema != nil || users.Parameters[0].Name != "X-Request-Id" || users.Security[0]["jwt"] == nil {
+		t.Fatal(users)
+	}
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/users/{id}": Method{"put": Operation{
+			Consumes: []string{"application/json"},
+			Parameters: []InputParameter{
+				{Name: "id", QueryType: "path", Type: "integer", Required: true},
+				{Name: "mode", QueryType: "query", Type: "string", Enum: []string{"full", "partial"}},
+				{Name: "X-Tenant", QueryType: "header", Type: "string", Required: true, Pattern: "^[a-z]+$"},
+				{Name: "User", QueryType: "body", Required: true, Schema: SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{
+					"age":  {Type: "number"},
+					"tags": {Type: "array", Items: &SchemaParameters{Type: "string"}},
+				}}},
+			},
+		}},
+	}}
+
+	var reported []Problem
+	for _, reportOnly := range []bool{false, true} {
+		router := mux.NewRouter()
+		router.HandleFunc("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		}).Methods("PUT")
+		router.Use(ValidationMiddleware(scheme, ValidationOptions{ReportOnly: reportOnly, Report: func(r *http.Request, problem Problem) {
+			reported = append(reported, problem)
+		}}))
+
+		request := httptest.NewRequest(http.MethodPut, "/api/users/1?mode=full", strings.NewReader(`{"age": 3, "tags": ["a"]}`))
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		request.Header.Set("X-Tenant", "acme")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK || recorder.Body.String() != `{"age": 3, "tags": ["a"]}` {
+			t.Fatal(recorder.Code, recorder.Body.String())
+		}
+
+		request = httptest.NewRequest(http.MethodPut, "/api/users/a?mode=other", strings.NewReader(`{"age": "3", "tags": [1]}`))
+		request.Header.Set("Content-Type", "application/json")
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if reportOnly && recorder.Code != http.StatusOK {
+			t.Fatal(recorder.Code)
+		}
+
+		if !reportOnly {
+			var problem Problem
+			json.NewDecoder(recorder.Body).Decode(&problem)
+			if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Content-Type") != problemContentType || len(problem.Errors) != 5 {
+				t.Fatal(recorder.Code, problem)
+			}
+		}
+
+		request = httptest.NewRequest(http.MethodPut, "/api/users/1", strings.NewReader(`{}`))
+		request.Header.Set("Content-Type", "text/plain")
+		request.Header.Set("X-Tenant", "acme")
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if !reportOnly && recorder.Code != http.StatusUnsupportedMediaType {
+			t.Fatal(recorder.Code, recorder.Body.String())
+		}
+	}
+
+	if len(reported) != 4 || reported[0].Detail != "path id must be an integer; query mode must be one of full, partial; header X-Tenant is required; body age must be a number; body tags[0] must be a string" {
+		t.Fatal(reported)
+	}
+}
+
+func TestValidationWithoutRouter(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/users/{user-id}/posts/{id}": Method{"get": Operation{Parameters: []InputParameter{
+			{Name: "user-id", QueryType: "path", Type: "string", Required: true},
+			{Name: "id", QueryType: "path", Type: "integer", Required: true},
+		}}},
+	}}
+
+	handler := ValidationMiddleware(scheme, ValidationOptions{Report: func(r *http.Request, problem Problem) {}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/users/ann/posts/3", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/users/ann/posts/third", nil))
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "path id must be an integer") {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestValidateSchemaConstraints(t *testing.T) {
+	minimum, maximum, minLength, maxLength := 1.0, 10.0, 2, 4
+	schema := SchemaParameters{Type: "object", Required: []string{"name", "age"}, Properties: map[string]SchemaParameters{
+		"name":  {Type: "string", MinLength: &minLength, MaxLength: &maxLength, Pattern: "^[a-z]+$"},
+		"age":   {Type: "integer", Minimum: &minimum, Maximum: &maximum},
+		"level": {Type: "integer", Enum: []string{"1", "2"}},
+		"admin": {Type: "boolean", Enum: []string{"false"}},
+		"bio":   {Type: "string"},
+	}}
+
+	for body, detail := range map[string]string{
+		`{"name": "ann", "age": 3, "level": 2, "admin": false, "bio": null}`: "",
+		`{"age": 3}`:                               "body name is required",
+		`{"name": null, "age": 3}`:                 "body name must not be null",
+		`{"name": "a", "age": 3}`:                  "body name must be at least 2 characters long",
+		`{"name": "annabel", "age": 3}`:            "body name must be at most 4 characters long",
+		`{"name": "Ann", "age": 3}`:                "body name must match ^[a-z]+$",
+		`{"name": "ann", "age": 0}`:                "body age must be at least 1",
+		`{"name": "ann", "age": 11}`:               "body age must be at most 10",
+		`{"name": "ann", "age": 3, "level": 3}`:    "body level must be one of 1, 2",
+		`{"name": "ann", "age": 3, "admin": true}`: "body admin must be one of false",
+	} {
+		var value interface{}
+		decoder := json.NewDecoder(strings.NewReader(body))
+		decoder.UseNumber()
+		decoder.Decode(&value)
+		problem := Problem{}
+		validateSchema(value, schema, "body", "", &problem)
+		problem.summarize()
+		if problem.Detail != detail {
+			t.Fatal(body, problem.Detail)
+		}
+	}
+}
+
+func TestValidationPatternsAndForms(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/", Paths: PathsHolder{
+		"/upload": Method{"post": Operation{Consumes: []string{"multipart/form-data"}, Parameters: []InputParameter{
+			{Name: "kind", QueryType: "query", Type: "string", Pattern: "^(?!raw)[a-z]+$"},
+			{Name: "name", QueryType: "formData", Type: "string"},
+		}}},
+	}}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	router.Use(ValidationMiddleware(scheme, ValidationOptions{MaxBodySize: 256, Report: func(r *http.Request, problem Problem) {}}))
+	for _, size := range []int{16, 1024} {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("name", strings.Repeat("a", size))
+		writer.Close()
+
+		request := httptest.NewRequest(http.MethodPost, "/upload?kind=image", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if size < 256 && recorder.Code != http.StatusOK || size > 256 && recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal(size, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	//the pattern uses syntax RE2 doesn't support, it is reported once and not checked
+	if strings.Count(logged.String(), "pattern ^(?!raw)[a-z]+$ is not checked") != 1 {
+		t.Fatal(logged.String())
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
+
+func TestValidationBodyLimit(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/", Paths: PathsHolder{
+		"/users": Method{"post": Operation{Parameters: []InputParameter{
+			{Name: "User", QueryType: "body", Required: true, Schema: SchemaParameters{Type: "object"}},
+		}}},
+	}}
+
+	var read []string
+	router := mux.NewRouter()
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		read = append(read, fmt.Sprint(len(body), err))
+	}).Methods("POST")
+	router.Use(ValidationMiddleware(scheme, ValidationOptions{MaxBodySize: 16, Report: func(r *http.Request, problem Problem) {}}))
+
+	for _, body := range []string{`{"name": "ann"}`, `{"name": "a longer name"}`} {
+		//without a content length the limit is only found while reading
+		request := httptest.NewRequest(http.MethodPost, "/users", ioutil.NopCloser(strings.NewReader(body)))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if len(body) <= 16 && recorder.Code != http.StatusOK {
+			t.Fatal(recorder.Code, recorder.Body.String())
+		}
+
+		if len(body) > 16 && (recorder.Code != http.StatusRequestEntityTooLarge || !strings.Contains(recorder.Body.String(), "must not exceed 16 bytes")) {
+			t.Fatal(recorder.Code, recorder.Body.String())
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/users", failingReader{})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "body could not be read: connection reset") {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+
+	if len(read) != 1 || read[0] != "15 <nil>" {
+		t.Fatal(read)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/users/{id}": Method{"get": Operation{Responses: map[string]OperationResponse{
+			"200": {Description: "OK", Schema: &SchemaParameters{Type: "object", Required: []string{"name"}, Properties: map[string]SchemaParameters{
+				"name": {Type: "string"},
+			}}},
+			"404": {Description: "Not Found"},
+		}}},
+	}}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch mux.Vars(r)["id"] {
+		case "1":
+			w.Write([]byte(`{"name": "ada"}`))
+		case "2":
+			w.Write([]byte(`{"name": 2}`))
+		case "3":
+			w.WriteHeader(http.StatusTeapot)
+		case "4":
+			w.Write([]byte(`{"nickname": "ada"}`))
+		}
+	}
+
+	var reported []Problem
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id}", handler).Methods("GET")
+	router.Use(ResponseValidationMiddleware(scheme, ResponseValidationOptions{Fail: true, Report: func(r *http.Request, problem Problem) {
+		reported = append(reported, problem)
+	}}))
+
+	for id, code := range map[string]int{"1": http.StatusOK, "2": http.StatusInternalServerError, "3": http.StatusInternalServerError, "4": http.StatusInternalServerError} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/users/"+id, nil))
+		if recorder.Code != code {
+			t.Fatal(id, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	if len(reported) != 3 {
+		t.Fatal(reported)
+	}
+
+	//without a router the operation is found from the path templates
+	request := httptest.NewRequest(http.MethodGet, "/api/users/2", nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, mux.SetURLVars(request, map[string]string{"id": "2"}))
+	problem, ok := ValidateResponse(scheme, request, recorder.Code, recorder.Header(), recorder.Body.Bytes())
+	if ok || problem.Detail != "response name must be a string" {
+		t.Fatal(problem)
+	}
+
+	//a response which drifted from the documented model by dropping a required field
+	request = httptest.NewRequest(http.MethodGet, "/api/users/4", nil)
+	problem, ok = ValidateResponse(scheme, request, http.StatusOK, http.Header{}, []byte(`{"nickname": "ada"}`))
+	if ok || problem.Detail != "response name is required" {
+		t.Fatal(problem)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/api/users/3", nil)
+	problem, ok = ValidateResponse(scheme, request, http.StatusTeapot, http.Header{}, nil)
+	if ok || problem.Detail != "status 418 is not documented" {
+		t.Fatal(problem)
+	}
+}
+
+func TestResponseValidationStreaming(t *testing.T) {
+	scheme := &SchemeHolder{BasePath: "/", Paths: PathsHolder{
+		"/events": Method{"get": Operation{Responses: map[string]OperationResponse{"204": {Description: "No Content"}}}},
+		"/socket": Method{"get": Operation{Responses: map[string]OperationResponse{"101": {Description: "Switching Protocols"}}}},
+	}}
+
+	var reported []Problem
+	router := mux.NewRouter()
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: started\n\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("data: done\n\n"))
+	})
+	router.HandleFunc("/socket", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("upgrade requests are not wrapped")
+		}
+	})
+	router.Use(ResponseValidationMiddleware(scheme, ResponseValidationOptions{Fail: true, Report: func(r *http.Request, problem Problem) {
+		reported = append(reported, problem)
+	}}))
+
+	//the undocumented 200 of a streamed response is not validated
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !recorder.Flushed || recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/event-stream" ||
+		recorder.Body.String() != "data: started\n\ndata: done\n\n" {
+		t.Fatal(recorder.Flushed, recorder.Code, recorder.Body.String())
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/socket", nil)
+	request.Header.Set("Upgrade", "websocket")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	if len(reported) != 0 {
+		t.Fatal(reported)
+	}
+
+	if _, _, err := (&responseRecorder{ResponseWriter: httptest.NewRecorder()}).Hijack(); err == nil {
+		t.Fatal("recorders can't be hijacked")
+	}
+}
+
+func TestSchemaLearner(t *testing.T) {
+	learner := NewSchemaLearner(1)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		body["id"] = mux.Vars(r)["id"]
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}).Methods("PUT")
+	router.Use(learner.Middleware)
+
+	for _, body := range []string{
+		`{"email": "ada@example.com", "age": 36, "createdAt": "2020-01-02T10:00:00Z", "tags": ["a"]}`,
+		`{"email": "grace@example.com", "age": 85.5, "createdAt": "2020-03-04T10:00:00Z", "nickname": null}`,
+	} {
+		request := httptest.NewRequest(http.MethodPut, "/api/users/1", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	learned := learner.Schemas()
+	request := learned["put /api/users/{id}"].Request
+	if request == nil || request.Type != "object" || request.Properties["age"].Type != "number" ||
+		request.Properties["email"].Format != "email" || request.Properties["createdAt"].Format != "date-time" ||
+		request.Properties["tags"].Items.Type != "string" || strings.Join(request.Required, ",") != "age,createdAt,email" {
+		t.Fatal(request)
+	}
+
+	if _, ok := request.Properties["nickname"]; ok {
+		t.Fatal("properties only seen as null have no type", request)
+	}
+
+	response := learned["put /api/users/{id}"].Responses["200"]
+	if response.Properties["id"].Type != "string" || !containsString(response.Required, "id") {
+		t.Fatal(response)
+	}
+
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{"/users/{id}": Method{"put": Operation{
+		Parameters: []InputParameter{{Name: "body", QueryType: "body", Schema: SchemaParameters{Type: "object"}}},
+		Responses:  map[string]OperationResponse{"200": {Description: "OK"}, "404": {Description: "Not Found"}},
+	}}}}
+
+	if count := learned.Apply(scheme); count != 2 {
+		t.Fatal(count)
+	}
+
+	operation := scheme.Paths["/users/{id}"]["put"]
+	if len(operation.Parameters[0].Schema.Properties) != 4 || operation.Responses["200"].Schema == nil || operation.Responses["404"].Schema != nil {
+		t.Fatal(operation)
+	}
+
+	//schemas which were inferred statically are only completed
+	typed := SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{"age": {Type: "integer"}, "tags": {Type: "array"}}}
+	filled, ok := fillSchema(typed, *request)
+	if !ok || filled.Properties["age"].Type != "integer" || filled.Properties["tags"].Items.Type != "string" || len(filled.Properties) != 2 {
+		t.Fatal(filled)
+	}
+}
+
+func TestSchemaLearnerStreaming(t *testing.T) {
+	learner := NewSchemaLearner(1)
+	router := mux.NewRouter()
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"event": "started"}`))
+		w.(http.Flusher).Flush()
+	})
+	router.HandleFunc("/socket", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("upgrade requests are not wrapped")
+		}
+	})
+	router.Use(learner.Middleware)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !recorder.Flushed || recorder.Body.String() != `{"event": "started"}` {
+		t.Fatal(recorder.Flushed, recorder.Body.String())
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/socket", nil)
+	request.Header.Set("Upgrade", "websocket")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	if learned := learner.Schemas(); len(learned) != 0 {
+		t.Fatal(learned)
+	}
+
+	//the wrapper only hijacks when the writer it wraps can
+	if _, _, err := (&teeResponseWriter{ResponseWriter: httptest.NewRecorder()}).Hijack(); err == nil {
+		t.Fatal("recorders can't be hijacked")
+	}
+}
+
+func TestMockHandler(t *testing.T) {
+	user := SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{
+		"email": {Type: "string", Format: "email"},
+		"age":   {Type: "integer"},
+		"role":  {Type: "string", Enum: []string{"admin", "member"}},
+	}}
+
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/users/{id}": Method{
+			"get": Operation{
+				Parameters: []InputParameter{{Name: "id", QueryType: "path", Type: "integer", Required: true}},
+				Responses:  map[string]OperationResponse{"200": {Schema: &user}, "404": {}},
+			},
+			"delete": Operation{Responses: map[string]OperationResponse{"204": {}}},
+		},
+		"/users": Method{"post": Operation{
+			Responses: map[string]OperationResponse{"201": {Examples: map[string]interface{}{"application/json": map[interface{}]interface{}{"id": 1}}}},
+		}},
+	}}
+
+	handler := MockHandler(scheme, MockOptions{Seed: 7, Routes: map[string]MockRoute{"delete /users/{id}": {ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}}})
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+		return recorder
+	}
+
+	first := serve(http.MethodGet, "/api/users/1")
+	var body map[string]interface{}
+	json.Unmarshal(first.Body.Bytes(), &body)
+	if first.Code != http.StatusOK || !strings.HasSuffix(body["email"].(string), "@example.com") || (body["role"] != "admin" && body["role"] != "member") {
+		t.Fatal(first.Code, first.Body.String())
+	}
+
+	if second := serve(http.MethodGet, "/api/users/1"); second.Body.String() != first.Body.String() {
+		t.Fatal("responses must be deterministic", first.Body.String(), second.Body.String())
+	}
+
+	if recorder := serve(http.MethodGet, "/api/users/abc"); recorder.Code != http.StatusBadRequest {
+		t.Fatal(recorder.Code)
+	}
+
+	if recorder := serve(http.MethodPost, "/api/users"); recorder.Code != http.StatusCreated || strings.TrimSpace(recorder.Body.String()) != `{"id":1}` {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+
+	if recorder := serve(http.MethodDelete, "/api/users/1"); recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Content-Type") != problemContentType {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestExamples(t *testing.T) {
+	maximum, minLength := 10.0, 12
+	user := SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{
+		"id":        {Type: "integer"},
+		"email":     {Type: "string"},
+		"createdAt": {Type: "string"},
+		"name":      {Type: "string"},
+		"status":    {Type: "string", Enum: []string{"active"}},
+		"score":     {Type: "number", Maximum: &maximum},
+		"code":      {Type: "string", MinLength: &minLength},
+		"tags":      {Type: "array", Items: &SchemaParameters{Type: "string"}},
+	}}
+
+	example := Example(user, "user", 1).(map[string]interface{})
+	if _, err := time.Parse(time.RFC3339, example["createdAt"].(string)); err != nil {
+		t.Fatal(example)
+	}
+
+	if !strings.HasSuffix(example["email"].(string), "@example.com") || example["status"] != "active" ||
+		example["score"].(float64) > maximum || len(example["code"].(string)) < minLength || !strings.Contains(example["name"].(string), " ") {
+		t.Fatal(example)
+	}
+
+	if !reflect.DeepEqual(example, Example(user, "user", 1)) {
+		t.Fatal("examples must be deterministic")
+	}
+
+	scheme := &SchemeHolder{Paths: PathsHolder{"/users/{id}": Method{"put": Operation{
+		Parameters: []InputParameter{
+			{Name: "id", QueryType: "path", Type: "integer", Required: true},
+			{Name: "User", QueryType: "body", Schema: user},
+		},
+		Responses: map[string]OperationResponse{"200": {Schema: &user}, "204": {}},
+	}}}}
+
+	scheme.AddExamples(0)
+	operation := scheme.Paths["/users/{id}"]["put"]
+	if _, ok := operation.Parameters[0].Example.(int64); !ok || operation.Parameters[1].Schema.Example == nil || operation.Responses["204"].Examples != nil {
+		t.Fatal(operation)
+	}
+
+	//generated examples match the schema they were generated from
+	encoded, _ := json.Marshal(operation.Responses["200"].Examples[jsonContentType])
+	decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+	decoder.UseNumber()
+	var decoded interface{}
+	decoder.Decode(&decoded)
+	var problem Problem
+	validateSchema(decoded, user, "response", "", &problem)
+	if len(problem.Errors) > 0 {
+		t.Fatal(problem.Errors, string(encoded))
+	}
+}
+
+func TestPatternExamples(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, pattern := range []string{`^[0-9a-f]{24}$`, `^[A-Z]{2}-\d{3,5}$`, `^(eu|us)-[a-z]+\.example$`, `^\w+@\w+\.com$`, `[^/]+`, `^v\d+(\.\d+)?$`} {
+		value, ok := examplePattern(pattern, random)
+		if !ok || !regexp.MustCompile(pattern).MatchString(value) {
+			t.Fatal(pattern, value)
+		}
+	}
+
+	if value, ok := examplePattern(`^\bfoo\b$`, random); ok {
+		t.Fatal(value)
+	}
+
+	order := SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{
+		"id":   {Type: "string", Pattern: "^[0-9a-f]{24}$"},
+		"code": {Type: "string", Pattern: `^[A-Z]{2}-\d{3}$`},
+		"slug": {Type: "string", Pattern: `^\bslug\b$`},
+	}}
+
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/orders/{id}": Method{"get": Operation{
+			Parameters: []InputParameter{{Name: "id", QueryType: "path", Type: "string", Required: true, Pattern: "^[0-9a-f]{24}$"}},
+			Responses:  map[string]OperationResponse{"200": {Schema: &order}},
+		}},
+	}}
+
+	//the mock validates the generated path against the pattern and answers with the generated body
+	request, err := ExampleRequest(scheme, "get", "/orders/{id}", 0)
+	if err != nil || !regexp.MustCompile(`^/api/orders/[0-9a-f]{24}$`).MatchString(request.URL.Path) {
+		t.Fatal(err, request)
+	}
+
+	recorder := httptest.NewRecorder()
+	MockHandler(scheme, MockOptions{}).ServeHTTP(recorder, request)
+	var body map[string]string
+	json.NewDecoder(recorder.Body).Decode(&body)
+	if _, ok := body["slug"]; ok || !regexp.MustCompile(`^[A-Z]{2}-\d{3}$`).MatchString(body["code"]) || len(body["id"]) != 24 {
+		t.Fatal(body)
+	}
+
+	diagnostics := scheme.AddExamples(0)
+	if len(diagnostics) != 1 || diagnostics[0].Kind != DiagnosticExample || diagnostics[0].Route != "/api/orders/{id}" || !strings.Contains(diagnostics[0].Reason, `^\bslug\b$ of the 200 response`) {
+		t.Fatal(diagnostics)
+	}
+
+	//a path parameter without a value can't be requested
+	scheme.Paths["/orders/{id}"]["get"].Parameters[0].Pattern = `\bid`
+	scheme.Paths["/orders/{id}"]["get"].Parameters[0].Example = nil
+	if _, err := ExampleRequest(scheme, "get", "/orders/{id}", 0); err == nil {
+		t.Fatal("the request has no id")
+	}
+}
+
+func TestContract(t *testing.T) {
+	user := SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{"id": {Type: "integer"}, "name": {Type: "string"}}}
+	scheme := &SchemeHolder{BasePath: "/api", Paths: PathsHolder{
+		"/users/{id}": Method{"get": Operation{
+			Parameters: []InputParameter{
+				{Name: "id", QueryType: "path", Type: "integer", Required: true},
+				{Name: "fields", QueryType: "query", Type: "string", Required: true, Enum: []string{"all"}},
+			},
+			Responses: map[string]OperationResponse{"200": {Schema: &user}},
+		}},
+		"/users": Method{"post": Operation{
+			Parameters: []InputParameter{{Name: "User", QueryType: "body", Required: true, Schema: user}},
+			Responses:  map[string]OperationResponse{"201": {Schema: &user}},
+		}},
+	}}
+
+	request, err := ExampleRequest(scheme, "GET", "/users/{id}", 0)
+	if err != nil || !regexp.MustCompile(`^/api/users/\d+\?fields=all$`).MatchString(request.URL.RequestURI()) {
+		t.Fatal(err, request.URL)
+	}
+
+	request, err = ExampleRequest(scheme, "post", "/users", 0)
+	if err != nil || request.Header.Get("Content-Type") != jsonContentType || request.Body == nil {
+		t.Fatal(err, request)
+	}
+
+	if _, err = ExampleRequest(scheme, "delete", "/users", 0); err == nil {
+		t.Fatal("the operation is not documented")
+	}
+
+	source, err := GenerateContractTest(scheme, ContractTestOptions{Package: "server", Handler: "NewRouter()", SpecPath: "docs/swagger.json"})
+	if err != nil || !strings.Contains(string(source), `contracttest.Run(t, NewRouter(), scheme`) || !strings.Contains(string(source), `"post /users",`) {
+		t.Fatal(err, string(source))
+	}
+}
+
+func TestGenerateClient(t *testing.T) {
+	holders, err := GetInfoFromSource("testdata/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//path variables named like the imports, including the package of the reused types, must not shadow them
+	holders = append(holders, RouteHolder{Route: "/links/{url}/{static}/{nil}/{fmt}", Methods: []string{"GET"}, Name: "GetLink"})
+	source, err := GenerateClient(holders, ClientOptions{Package: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compileClient(t, source)
+	for _, expected := range []string{
+		`static "github.com/plicca/summerfish-swagger/testdata/static"`,
+		"func (c *Client) CreateUser(ctx context.Context, body static.User) (result json.RawMessage, err error)",
+		"func (c *Client) GetUser(ctx context.Context, id int64) (result static.User, err error)",
+		"func (c *Client) GetLink(ctx context.Context, urlParam string, staticParam string, nilParam string, fmtParam string) (result json.RawMessage, err error)",
+		"func (c *Client) Stats(ctx context.Context, params StatsParams) (result json.RawMessage, err error)",
+		`header.Set("Content-Type", "application/json")`,
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Fatal(expected, string(source))
+		}
+	}
+
+	defer ClearOverrides()
+	Describe(dummyHandler).Response(http.StatusOK, []Problem{})
+	source, err = GenerateClient([]RouteHolder{
+		{Route: "/errors", Methods: []string{"GET"}, Name: "ListErrors", Handler: getFunctionName(reflect.ValueOf(dummyHandler))},
+		{Route: "/errors", Methods: []string{"HEAD"}, Name: "ListErrors"},
+	}, ClientOptions{Package: "client"})
+	if err != nil || !strings.Contains(string(source), "ListErrors(ctx context.Context) (result []summerfish.Problem, err error)") || !strings.Contains(string(source), "ListErrors2(ctx") {
+		t.Fatal(err, string(source))
+	}
+
+	for name, expected := range map[string]string{"user_id": "userID", "X-Request-Id": "xRequestID", "type": "typeParam", "2fa": "N2fa"} {
+		if identifier := goIdentifier(name, false); identifier != expected {
+			t.Fatal(name, identifier)
+		}
+	}
+}
+
+func TestCodegenOperations(t *testing.T) {
+	overrides := NewOverrides()
+	overrides.DescribeRoute("GET", "/users/{id}").
+		Param(InputParameter{Name: "x-request-id", QueryType: "header", Type: "string"}).
+		Param(InputParameter{Name: "page", QueryType: "query", Type: "integer"}).
+		Param(InputParameter{Name: "X-Trace", QueryType: "header", Type: "string", Required: true})
+
+	routes := []RouteHolder{
+		{Route: "/users/{id:[0-9]+}", Methods: []string{"GET"}, Name: "GetUser", Query: []NameType{{Name: "page", Type: "integer"}},
+			Headers:   []NameType{{Name: "X-Request-Id", Type: "string"}},
+			Responses: map[string]OperationResponse{"200": {Schema: &SchemaParameters{Type: "object"}}}},
+		{Route: "/users", Methods: []string{"POST"}, Body: NameType{Name: "User", StructName: "static.User"}, Responses: map[string]OperationResponse{"204": {}}},
+		{Route: "/unused"},
+	}
+
+	operations := codegenOperations(routes, overrides)
+	if len(operations) != 2 {
+		t.Fatal(operations)
+	}
+
+	//the overridden parameters are merged once, whatever the case of the header names
+	get := operations[0]
+	if get.Method != "GET" || get.Route != "/users/{id}" || len(get.Path) != 1 || get.Path[0].Pattern != "^[0-9]+$" ||
+		len(get.Query) != 1 || len(get.Headers) != 2 || get.Headers[1].Name != "X-Trace" || !get.Headers[1].IsRequired {
+		t.Fatal(get)
+	}
+
+	if !get.HasResult || get.Result != nil || get.ResultSchema.Type != "object" {
+		t.Fatal(get)
+	}
+
+	post := operations[1]
+	if post.Body == nil || post.Body.StructName != "static.User" || !post.HasResult || post.Result != nil || post.ResultSchema != nil {
+		t.Fatal(post)
+	}
+
+	client, err := GenerateClient(routes, ClientOptions{Package: "client", Overrides: overrides})
+	if err != nil || strings.Count(string(client), "// header X-Request-Id") != 1 || !strings.Contains(string(client), "// header X-Trace, required") {
+		t.Fatal(err, string(client))
+	}
+
+	typescript, err := GenerateTypeScript(routes, TypeScriptOptions{Overrides: overrides})
+	if err != nil || strings.Count(string(typescript), `"X-Request-Id"?: string`) != 1 || !strings.Contains(string(typescript), `"X-Trace": string`) {
+		t.Fatal(err, string(typescript))
+	}
+
+	compileClient(t, client)
+}
+
+// compileClient vets the generated client as a package of the module, so that it can import the reused types
+func compileClient(t *testing.T, source []byte) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to compile the client")
+	}
+
+	dir, err := ioutil.TempDir("testdata", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "client.go"), source, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output, err := exec.Command("go", "vet", "./"+filepath.ToSlash(dir)).CombinedOutput(); err != nil {
+		t.Fatal(err, string(output), string(source))
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	holders, err := GetInfoFromSource("testdata/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := GenerateTypeScript(holders, TypeScriptOptions{BaseURL: "https://api.example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"export interface User {\n  name: string;\n  email: string;\n  nickname?: string;\n}",
+		"export function getUser(id: number, options?: RequestOptions): Promise<User>",
+		"`/api/v1/users/${encodeURIComponent(String(id))}`",
+		"export function createUser(body: User, options?: RequestOptions): Promise<unknown>",
+		"export function stats(params: StatsParams, options?: RequestOptions)",
+		`baseUrl: "https://api.example.com"`,
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Fatal(expected, string(source))
+		}
+	}
+
+	type status string
+	type manager struct {
+		Name string `json:"name"`
+	}
+
+	type member struct {
+		ID      int64    `json:"id"`
+		Status  status   `json:"status"`
+		Manager *manager `json:"manager,omitempty"`
+		Tags    []string `json:"tags"`
+		secret  string
+	}
+
+	//names used by the generated module or taken by a Params interface are numbered
+	type Headers struct {
+		Name string `json:"name"`
+	}
+
+	type ListMembersParams struct {
+		Page int `json:"page"`
+	}
+
+	defer ClearOverrides()
+	DescribeRoute("GET", "/headers").Response(http.StatusOK, Headers{})
+	DescribeRoute("DELETE", "/members").Response(http.StatusOK, ListMembersParams{})
+	OverrideSchema(status(""), SchemaParameters{Type: "string", Enum: []string{"active", "blocked"}})
+	Describe(dummyHandler).Response(http.StatusOK, []member{}).Param(InputParameter{Name: "X-Request-Id", QueryType: "header", Type: "string"})
+	source, err = GenerateTypeScript([]RouteHolder{
+		{Route: "/members", Methods: []string{"GET"}, Name: "ListMembers", Handler: getFunctionName(reflect.ValueOf(dummyHandler))},
+		{Route: "/members", Methods: []string{"DELETE"}, Name: "delete"},
+		{Route: "/headers", Methods: []string{"GET"}, Name: "fetch"},
+	}, TypeScriptOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"export interface Member {\n  id: number;\n  status: \"active\" | \"blocked\";\n  manager?: Manager;\n  tags: string[];\n}",
+		"export interface Manager {\n  name: string;\n}",
+		"export interface ListMembersParams {\n  \"X-Request-Id\"?: string;\n}",
+		"export function listMembers(params: ListMembersParams = {}, options?: RequestOptions): Promise<Member[]>",
+		`"X-Request-Id": params["X-Request-Id"],`,
+		"export function deleteParam(options?: RequestOptions): Promise<ListMembersParams2>",
+		"export interface ListMembersParams2 {\n  page: number;\n}",
+		"export function fetchParam(options?: RequestOptions): Promise<Headers2>",
+		"export interface Headers2 {\n  name: string;\n}",
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Fatal(expected, string(source))
+		}
+	}
+}
+
+func TestSpecHandler(t *testing.T) {
+	scheme := SchemeHolder{BasePath: "/"}
+	scheme.Build([]RouteHolder{{Route: "/ping", Methods: []string{"GET"}, Name: "Ping"}})
+	specHandler, err := SpecHandler(&scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		headers     map[string]string
+		contentType string
+		encoding    string
+	}{
+		{"Default json", "/swagger", nil, jsonContentType, ""},
+		{"Accept yaml", "/swagger", map[string]string{"Accept": "application/yaml"}, yamlContentType, ""},
+		{"Format override", "/swagger?format=yaml", map[string]string{"Accept": "application/json"}, yamlContentType, ""},
+		{"Gzip", "/swagger", map[string]string{"Accept-Encoding": "gzip, deflate"}, jsonContentType, "gzip"},
+		{"Gzip refused", "/swagger", map[string]string{"Accept-Encoding": "gzip;q=0, deflate"}, jsonContentType, ""},
+		{"Any encoding", "/swagger", map[string]string{"Accept-Encoding": "br, *;q=0.1"}, jsonContentType, "gzip"},
+		{"Identity only", "/swagger", map[string]string{"Accept-Encoding": "identity, *;q=0"}, jsonContentType, ""},
+		{"Weighted yaml", "/swagger", map[string]string{"Accept": "application/json;q=0.5, application/yaml"}, yamlContentType, ""},
+		{"Wildcard json", "/swagger", map[string]string{"Accept": "application/yaml;q=0.8, */*"}, jsonContentType, ""},
+		{"Yaml refused", "/swagger", map[string]string{"Accept": "text/yaml;q=0, application/*;q=0.2"}, jsonContentType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for key, value := range tt.headers {
+				request.Header.Set(key, value)
+			}
+
+			recorder := httptest.NewRecorder()
+			specHandler.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != tt.contentType || recorder.Header().Get("Content-Encoding") != tt.encoding {
+				t.Fatal(recorder.Code, recorder.Header())
+			}
+
+			request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+			recorder = httptest.NewRecorder()
+			specHandler.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusNotModified {
+				t.Fatal(recorder.Code)
+			}
+		})
+	}
+}
+
+func TestGetInfoFromSource(t *testing.T) {
+	holders, err := GetInfoFromSource("testdata/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := SchemeHolder{BasePath: "/"}
+	scheme.Build(holders)
+	expected := map[string]string{
+		"/ping":              "get",
+		"/api/v1/users/{id}": "get",
+		"/api/v1/users":      "post",
+		"/admin/stats":       "get",
+	}
+
+	if len(scheme.Paths) != len(expected) {
+		t.Fatal(scheme.Paths)
+	}
+
+	for path, method := range expected {
+		if _, ok := scheme.Paths[path][method]; !ok {
+			t.Fatal(path, method, scheme.Paths[path])
+		}
+	}
+
+	createUser := scheme.Paths["/api/v1/users"]["post"]
+	if len(createUser.Parameters) != 1 || len(createUser.Parameters[0].Schema.Properties) != 3 || createUser.Consumes[0] != "application/json" {
+		t.Fatal(createUser)
+	}
+
+	//Password is tagged json:"-" and Nickname is a pointer, documented as its element type
+	encoded, err := json.Marshal(createUser.Parameters[0].Schema)
+	if err != nil || string(encoded) != `{"type":"object","properties":{"email":{"type":"string"},"name":{"type":"string"},"nickname":{"type":"string"}}}` {
+		t.Fatal(err, string(encoded))
+	}
+
+	if tag := scheme.Paths["/admin/stats"]["get"].Tags[0]; tag != "Backoffice" {
+		t.Fatal(tag)
+	}
+
+	for _, holder := range holders {
+		hasMiddleware := len(holder.Middlewares) == 1 && strings.HasSuffix(holder.Middlewares[0], "testdata/static.requireAdmin")
+		if hasMiddleware != (holder.Route == "/admin/stats") {
+			t.Fatal(holder.Route, holder.Middlewares)
+		}
+	}
+
+	getUser := scheme.Paths["/api/v1/users/{id}"]["get"]
+	if getUser.Summary != "Get a user by id" || getUser.Tags[0] != "Users" || !getUser.Deprecated || len(getUser.Responses) != 2 {
+		t.Fatal(getUser)
+	}
+
+	if notFound := getUser.Responses["404"]; notFound.Description != "user not found" || notFound.Schema.Properties["code"].Type != "number" {
+		t.Fatal(notFound)
+	}
+
+	if ok := getUser.Responses["200"]; ok.Description != "OK" || len(ok.Schema.Properties) != 3 {
+		t.Fatal(ok)
+	}
+}
+
+func TestDiffSchemes(t *testing.T) {
+	previous := &SchemeHolder{BasePath: "/", Paths: PathsHolder{
+		"/users/{id}": Method{"get": Operation{
+			Parameters: []InputParameter{
+				{Name: "id", QueryType: "path", Type: "string", Required: true},
+				{Name: "kind", QueryType: "query", Type: "string", Enum: []string{"admin", "user"}},
+			},
+			Responses: map[string]OperationResponse{"200": {Schema: &SchemaParameters{Type: "object", Required: []string{"name"}, Properties: map[string]SchemaParameters{
+				"name":  {Type: "string"},
+				"email": {Type: "string"},
+			}}}},
+		}, "put": Operation{
+			Parameters: []InputParameter{{Name: "User", QueryType: "body", Schema: SchemaParameters{Type: "object", Required: []string{"name"}, Properties: map[string]SchemaParameters{
+				"name":  {Type: "string"},
+				"email": {Type: "string"},
+			}}}},
+		}},
+		"/ping": Method{"get": Operation{}},
+	}}
+
+	current := &SchemeHolder{BasePath: "/", Paths: PathsHolder{
+		"/users/{id}": Method{"get": Operation{
+			Parameters: []InputParameter{
+				{Name: "id", QueryType: "path", Type: "integer", Required: true},
+				{Name: "kind", QueryType: "query", Type: "string", Enum: []string{"admin"}},
+				{Name: "page", QueryType: "query", Type: "integer", Required: true},
+				{Name: "debug", QueryType: "query", Type: "boolean"},
+			},
+			Responses: map[string]OperationResponse{"200": {Schema: &SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{
+				"name": {Type: "string"},
+				"age":  {Type: "number"},
+			}}}},
+		}, "put": Operation{
+			Parameters: []InputParameter{{Name: "User", QueryType: "body", Schema: SchemaParameters{Type: "object", Required: []string{"email", "team"}, Properties: map[string]SchemaParameters{
+				"name":  {Type: "string"},
+				"email": {Type: "string"},
+				"team":  {Type: "string"},
+			}}}},
+		}},
+		"/status": Method{"get": Operation{}},
+	}}
+
+	expected := map[string]string{
+		"path removed": ChangeBreaking,
+		"path added":   ChangeNonBreaking,
+		"parameter id in path changed type from string to integer": ChangeBreaking,
+		"parameter kind in query no longer has value user":         ChangeBreaking,
+		"parameter page in query added as required":                ChangeBreaking,
+		"parameter debug in query added":                           ChangeNonBreaking,
+		"response 200.email removed":                               ChangeBreaking,
+		"response 200.age added":                                   ChangeNonBreaking,
+		"response 200.name became optional":                        ChangeBreaking,
+		"request body.email became required":                       ChangeBreaking,
+		"request body.name became optional":                        ChangeNonBreaking,
+		"request body.team added as required":                      ChangeBreaking,
+	}
+
+	changes := DiffSchemes(previous, current)
+	if len(changes) != len(expected) || !changes.HasBreaking() {
+		t.Fatal(changes)
+	}
+
+	for _, change := range changes {
+		if expected[change.Message] != change.Level {
+			t.Fatal(change)
+		}
+	}
+
+	if !strings.Contains(changes.Markdown(), "## Breaking changes") {
+		t.Fatal(changes.Markdown())
+	}
+}
+
+func TestLint(t *testing.T) {
+	scheme := &SchemeHolder{Paths: PathsHolder{
+		"/users/{id}": Method{
+			"get": Operation{
+				ID:      "GetUser",
+				Summary: "Get User",
+				Parameters: []InputParameter{
+					{Name: "user", QueryType: "body", Schema: SchemaParameters{Type: "object", Properties: map[string]SchemaParameters{"address": {}}}},
+				},
+				Responses: map[string]OperationResponse{"200": {}},
+			},
+			"put": Operation{
+				ID:        "GetUser",
+				Responses: map[string]OperationResponse{"404": {}},
+			},
+		},
+	}}
+
+	issues, err := Lint(scheme, LintConfig{Severities: map[string]string{"operation-summary": SeverityWarning}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{
+		"duplicate-operation-id":      1,
+		"empty-type":                  1,
+		"path-parameters":             2,
+		"body-without-payload-method": 1,
+		"success-response":            1,
+		"operation-summary":           1,
+	}
+
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Rule]++
+	}
+
+	for rule, count := range expected {
+		if counts[rule] != count {
+			t.Fatal(rule, counts[rule], issues.Text())
+		}
+	}
+
+	if issues[0].Severity != SeverityError || issues.Count(SeverityInfo) != 0 {
+		t.Fatal(issues.Text())
+	}
+
+	issues, err = Lint(scheme, LintConfig{Disabled: []string{"path-parameters", "duplicate-operation-id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := issues.HasSeverity(SeverityError); err != nil || found {
+		t.Fatal(err, issues.Text())
+	}
+
+	//a typo must not be read as the most severe level
+	if _, err = issues.HasSeverity("warn"); err == nil {
+		t.Fatal("unknown severity accepted")
+	}
+
+	if _, err = Lint(scheme, LintConfig{Severities: map[string]string{"operation-summary": "warn"}}); err == nil {
+		t.Fatal("unknown severity override accepted")
+	}
+
+	if _, err := issues.SARIF("swagger.json"); err != nil {
+		t.Fatal(err)
+	}
+}
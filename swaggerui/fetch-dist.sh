@@ -0,0 +1,13 @@
+#!/bin/sh
+# Downloads the swagger-ui-dist assets that are embedded into the library.
+set -e
+
+VERSION=3.23.8
+DIR=$(dirname "$0")/dist
+
+curl -sSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$VERSION.tgz" |
+	tar -xz -C "$DIR" --strip-components=1 \
+		package/swagger-ui.css \
+		package/swagger-ui-bundle.js \
+		package/swagger-ui-standalone-preset.js \
+		package/favicon-32x32.png
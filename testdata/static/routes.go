@@ -0,0 +1,75 @@
+package static
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader is read by the handlers of other packages
+const RequestIDHeader = "X-Request-Id"
+
+type User struct {
+	Name     string  `json:"name"`
+	Email    string  `json:"email"`
+	Nickname *string `json:"nickname,omitempty"`
+	Password string  `json:"-"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+func NewRouter() *mux.Router {
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/ping", Ping).Methods("GET")
+
+	api := router.PathPrefix("/api/v1").Subrouter()
+	registerUserRoutes(api)
+
+	admin := router.PathPrefix("/admin").Name("Backoffice").Subrouter()
+	admin.Use(requireAdmin)
+	admin.Path("/stats").HandlerFunc(Stats).Methods(http.MethodGet).Queries("page", "{page:[0-9]+}")
+	return router
+}
+
+func registerUserRoutes(r *mux.Router) {
+	r.HandleFunc("/users/{id:[0-9]+}", GetUser).Methods("GET")
+	r.Handle("/users", http.HandlerFunc(CreateUser)).Methods("POST").Headers("Content-Type", "application/json")
+}
+
+func requireAdmin(next http.Handler) http.Handler {
+	return next
+}
+
+func Ping(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("pong"))
+}
+
+func Stats(w http.ResponseWriter, r *http.Request) {
+	page := r.URL.Query().Get("page")
+	w.Write([]byte(page))
+}
+
+// GetUser returns a single user.
+//
+// @summary Get a user by id
+// @tag Users
+// @response 200 User
+// @response 404 ErrorResponse "user not found"
+// @deprecated
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	w.Write([]byte(vars["id"]))
+}
+
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	var user User
+	err := json.NewDecoder(r.Body).Decode(&user)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,388 @@
+package summerfish
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tsIdentifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+var tsReservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true, "debugger": true,
+	"default": true, "delete": true, "do": true, "else": true, "enum": true, "export": true, "extends": true,
+	"false": true, "finally": true, "for": true, "function": true, "if": true, "import": true, "in": true,
+	"instanceof": true, "new": true, "null": true, "return": true, "super": true, "switch": true, "this": true,
+	"throw": true, "true": true, "try": true, "typeof": true, "var": true, "void": true, "while": true, "with": true,
+	"let": true, "static": true, "yield": true, "await": true, "implements": true, "interface": true, "package": true,
+	"private": true, "protected": true, "public": true, "arguments": true, "eval": true,
+	"params": true, "body": true, "options": true, "request": true, "defaults": true, "query": true, "headers": true,
+	"fetch": true, "encodeURIComponent": true,
+}
+
+// tsTypeNames are declared by the generated module or used by it from the globals, interfaces can't shadow them
+var tsTypeNames = []string{
+	"ApiError", "RequestOptions", "Value", "Headers", "FormData", "Error", "URL", "URLSearchParams", "Promise", "Record",
+	"RequestInit", "BodyInit", "Response", "Request", "Blob", "JSON", "String", "Object", "Array", "Number", "Boolean", "Date",
+}
+
+// TypeScriptOptions describes the module written by GenerateTypeScript, BaseURL is the default url of its requests
+// and Overrides the registry applied to the routes
+type TypeScriptOptions struct {
+	BaseURL   string
+	Overrides *Overrides
+}
+
+type tsField struct {
+	Key      string
+	Type     string
+	Optional bool
+}
+
+type tsInterface struct {
+	Name   string
+	Fields []tsField
+}
+
+type tsParameter struct {
+	Name     string
+	Key      string
+	Type     string
+	Value    string
+	Required bool
+}
+
+type tsOperation struct {
+	Name       string
+	Method     string
+	Route      string
+	Summary    string
+	Path       string
+	Args       string
+	Query      []tsParameter
+	Headers    []tsParameter
+	Params     []tsParameter
+	ParamsType string
+	Body       string
+	Result     string
+}
+
+// tsGenerator declares an interface for every named Go type reached from the operations
+type tsGenerator struct {
+	names      map[string]bool
+	declared   map[string]string
+	interfaces []tsInterface
+	overrides  *Overrides
+}
+
+// GenerateTypeScript writes a TypeScript module with an interface for every request and response struct,
+// named after the Go type and using its json names, and a fetch based function per route, named after RouteHolder.Name.
+// Fields with omitempty or pointers are optional and the enums of the schema overrides and parameters become unions.
+func GenerateTypeScript(routes []RouteHolder, options TypeScriptOptions) (source []byte, err error) {
+	g := &tsGenerator{names: map[string]bool{}, declared: map[string]string{}, overrides: options.Overrides}
+	for _, name := range tsTypeNames {
+		g.names[name] = true
+	}
+
+	data := struct {
+		BaseURL    string
+		Interfaces []tsInterface
+		Operations []tsOperation
+	}{BaseURL: strconv.Quote(strings.TrimSuffix(options.BaseURL, "/"))}
+
+	names := map[string]bool{}
+	for _, generated := range codegenOperations(routes, options.Overrides) {
+		operation := g.operation(generated)
+		//a handler served by several routes gets a function per route
+		for name, i := operation.Name, 2; names[operation.Name]; i++ {
+			operation.Name = fmt.Sprintf("%s%d", name, i)
+		}
+
+		if len(operation.ParamsType) > 0 {
+			base := strings.ToUpper(operation.Name[:1]) + operation.Name[1:] + "Params"
+			operation.ParamsType = base
+			for i := 2; g.names[operation.ParamsType]; i++ {
+				operation.ParamsType = fmt.Sprintf("%s%d", base, i)
+			}
+
+			g.names[operation.ParamsType] = true
+			operation.Args = strings.Replace(operation.Args, "params: Params", "params: "+operation.ParamsType, 1)
+		}
+
+		names[operation.Name] = true
+		data.Operations = append(data.Operations, operation)
+	}
+
+	data.Interfaces = g.interfaces
+	var buffer bytes.Buffer
+	err = codegenTemplates.ExecuteTemplate(&buffer, "client.ts.tmpl", data)
+	return buffer.Bytes(), err
+}
+
+func (g *tsGenerator) operation(generated codegenOperation) (operation tsOperation) {
+	operation.Method = generated.Method
+	operation.Route = generated.Route
+	operation.Summary = strings.Replace(generated.Summary, "*/", "* /", -1)
+	operation.Name = tsIdentifier(generated.Name)
+	if len(generated.Name) == 0 {
+		operation.Name = tsIdentifier(strings.ToLower(operation.Method) + " " + operation.Route)
+	}
+
+	var args []string
+	operation.Path = strings.NewReplacer("`", "\\`", "$", "\\$", "\\", "\\\\").Replace(operation.Route)
+	variables := map[string]bool{}
+	for _, entry := range generated.Path {
+		variable := tsIdentifier(entry.Name)
+		for variables[variable] {
+			variable += "Param"
+		}
+
+		variables[variable] = true
+		args = append(args, variable+": "+tsPrimitive(inferTypeFromPattern(entry.Type, entry.Pattern), entry.Enum))
+		operation.Path = strings.Replace(operation.Path, "{"+entry.Name+"}", "${encodeURIComponent(String("+variable+"))}", 1)
+	}
+
+	form := generated.Form
+	optional := true
+	add := func(in string, entry NameType) {
+		parameter := tsParameter{Name: strconv.Quote(entry.Name), Key: tsKey(entry.Name), Type: tsPrimitive(inferTypeFromPattern(entry.Type, entry.Pattern), entry.Enum), Required: entry.IsRequired}
+		switch {
+		case form && in == "header" && strings.EqualFold(entry.Name, contentTypeHeader):
+			//fetch sets the multipart boundary of FormData bodies
+			return
+		case entry.IsRequired && len(entry.Enum) == 1:
+			parameter.Value = strconv.Quote(entry.Enum[0])
+		default:
+			for _, existing := range operation.Params {
+				if existing.Name == parameter.Name {
+					return
+				}
+			}
+
+			parameter.Value = "params." + entry.Name
+			if parameter.Key != entry.Name {
+				parameter.Value = "params[" + parameter.Name + "]"
+			}
+
+			optional = optional && !entry.IsRequired
+			operation.Params = append(operation.Params, parameter)
+		}
+
+		if in == "query" {
+			operation.Query = append(operation.Query, parameter)
+		} else {
+			operation.Headers = append(operation.Headers, parameter)
+		}
+	}
+
+	for _, entry := range generated.Query {
+		add("query", entry)
+	}
+
+	for _, entry := range generated.Headers {
+		add("header", entry)
+	}
+
+	if len(operation.Params) > 0 {
+		operation.ParamsType = "Params"
+		if optional {
+			args = append(args, "params: Params = {}")
+		} else {
+			args = append(args, "params: Params")
+		}
+	}
+
+	operation.Body = "undefined"
+	if form {
+		operation.Body = "body"
+		args = append(args, "body: FormData")
+	} else if generated.Body != nil {
+		operation.Body = "body"
+		args = append(args, "body: "+g.typeOf(*generated.Body))
+	}
+
+	switch {
+	case generated.Result != nil:
+		operation.Result = g.typeOf(*generated.Result)
+	case generated.ResultSchema != nil:
+		operation.Result = tsSchema(*generated.ResultSchema)
+	case generated.HasResult:
+		operation.Result = "void"
+	default:
+		operation.Result = "unknown"
+	}
+
+	operation.Args = strings.Join(append(args, "options?: RequestOptions"), ", ")
+	return
+}
+
+// typeOf is the TypeScript type of a resolved Go type, structs are declared once and referenced by name
+func (g *tsGenerator) typeOf(entry NameType) (expr string) {
+	schema, overridden := g.overrides.lookupSchema(entry.StructName)
+	switch {
+	case overridden:
+		expr = tsSchema(schema)
+	case len(entry.StructName) > 0 && (len(entry.Children) > 0 || len(entry.Type) == 0):
+		expr = g.declare(entry)
+	case len(entry.Children) > 0:
+		expr = "{ " + tsInline(g.fields(entry.Children)) + " }"
+	default:
+		expr = tsPrimitive(entry.Type, entry.Enum)
+	}
+
+	if entry.IsArray {
+		expr = tsArray(expr)
+	}
+
+	return
+}
+
+func (g *tsGenerator) declare(entry NameType) string {
+	short := entry.StructName[strings.LastIndex(entry.StructName, ".")+1:]
+	key := entry.StructName
+	if len(entry.ImportPath) > 0 {
+		key = entry.ImportPath + "." + short
+	}
+
+	if name, ok := g.declared[key]; ok {
+		return name
+	}
+
+	base := camelCase(short, true)
+	if len(base) == 0 {
+		return "unknown"
+	}
+
+	//types with the same name in different packages are numbered
+	name := base
+	for i := 2; g.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+
+	g.names[name] = true
+	g.declared[key] = name
+	index := len(g.interfaces)
+	g.interfaces = append(g.interfaces, tsInterface{Name: name})
+	fields := g.fields(entry.Children)
+	g.interfaces[index].Fields = fields
+	return name
+}
+
+func (g *tsGenerator) fields(children []NameType) (fields []tsField) {
+	for _, child := range children {
+		fields = append(fields, tsField{Key: tsKey(child.Name), Type: g.typeOf(child), Optional: child.Optional})
+	}
+
+	return
+}
+
+// tsSchema writes the type of a schema inline, properties which are not required are optional
+func tsSchema(schema SchemaParameters) string {
+	switch schema.Type {
+	case "array":
+		if schema.Items == nil {
+			return "unknown[]"
+		}
+
+		return tsArray(tsSchema(*schema.Items))
+	case "object":
+		if len(schema.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+
+		var fields []tsField
+		for _, property := range sortedKeys(schema.Properties) {
+			fields = append(fields, tsField{Key: tsKey(property), Type: tsSchema(schema.Properties[property]), Optional: !containsString(schema.Required, property)})
+		}
+
+		return "{ " + tsInline(fields) + " }"
+	}
+
+	return tsPrimitive(schema.Type, schema.Enum)
+}
+
+// tsPrimitive maps Go and swagger types, enums become unions of their values
+func tsPrimitive(varType string, enum []string) string {
+	if mapped, ok := jsonMapping[varType]; ok {
+		varType = mapped
+	}
+
+	var values []string
+	switch varType {
+	case "integer", "number":
+		for _, value := range enum {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				values = append(values, value)
+			}
+		}
+
+		if len(values) == 0 || len(values) < len(enum) {
+			return "number"
+		}
+	case "", "string":
+		for _, value := range enum {
+			values = append(values, strconv.Quote(value))
+		}
+
+		if len(values) == 0 && len(varType) == 0 {
+			return "unknown"
+		}
+
+		if len(values) == 0 {
+			return "string"
+		}
+	case "boolean":
+		return "boolean"
+	case "object":
+		return "Record<string, unknown>"
+	case "file":
+		return "Blob"
+	default:
+		return "unknown"
+	}
+
+	return strings.Join(values, " | ")
+}
+
+func tsArray(expr string) string {
+	if strings.Contains(expr, " | ") {
+		return "(" + expr + ")[]"
+	}
+
+	return expr + "[]"
+}
+
+func tsInline(fields []tsField) string {
+	var parts []string
+	for _, field := range fields {
+		optional := ""
+		if field.Optional {
+			optional = "?"
+		}
+
+		parts = append(parts, field.Key+optional+": "+field.Type)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// tsKey quotes the property names which are not identifiers, e.g. X-Request-Id
+func tsKey(name string) string {
+	if tsIdentifierRegex.MatchString(name) {
+		return name
+	}
+
+	return strconv.Quote(name)
+}
+
+// tsIdentifier names functions and arguments in lowerCamelCase, avoiding the reserved words
+func tsIdentifier(name string) string {
+	identifier := camelCase(name, false)
+	if len(identifier) == 0 || tsReservedWords[identifier] {
+		return identifier + "Param"
+	}
+
+	return identifier
+}
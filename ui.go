@@ -0,0 +1,175 @@
+package summerfish
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:generate sh swaggerui/fetch-dist.sh
+
+const (
+	defaultUITitle           = "API Docs"
+	redocScriptURL           = "https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js"
+	rapiDocScriptURL         = "https://cdn.jsdelivr.net/npm/rapidoc@9.3.8/dist/rapidoc-min.js"
+	scalarScriptURL          = "https://cdn.jsdelivr.net/npm/@scalar/api-reference@1.25.0"
+	stoplightElementsBaseURL = "https://cdn.jsdelivr.net/npm/@stoplight/elements@8.4.0"
+)
+
+//go:embed swaggerui/index.html
+var swaggerUIIndex string
+
+//go:embed swaggerui/dist
+var swaggerUIFiles embed.FS
+
+//go:embed ui/*.html
+var uiTemplateFiles embed.FS
+
+var swaggerUITemplate = template.Must(template.New("index.html").Parse(swaggerUIIndex))
+var uiTemplates = template.Must(template.ParseFS(uiTemplateFiles, "ui/*.html"))
+
+// UIRenderer builds the handler of a documentation viewer that loads the spec from specURL.
+// The handler must be mounted with http.StripPrefix so that it receives the paths relative to the UI route.
+type UIRenderer interface {
+	Handler(specURL string) (http.Handler, error)
+}
+
+// SwaggerUI serves the Swagger UI from the swagger-ui-dist files embedded in swaggerui/dist,
+// AssetsURL can point to a self hosted copy of swagger-ui-dist instead
+type SwaggerUI struct {
+	Title     string
+	AssetsURL string
+}
+
+// ReDoc serves ReDoc, whose bundle is loaded from jsdelivr unless ScriptURL points to a self hosted redoc.standalone.js
+type ReDoc struct {
+	Title     string
+	ScriptURL string
+}
+
+// RapiDoc serves RapiDoc with try-it-out enabled, loaded from jsdelivr unless ScriptURL points to a self hosted rapidoc-min.js
+type RapiDoc struct {
+	Title     string
+	ScriptURL string
+}
+
+// Scalar serves the Scalar API reference, loaded from jsdelivr unless ScriptURL points to a self hosted standalone bundle
+type Scalar struct {
+	Title     string
+	ScriptURL string
+}
+
+// StoplightElements serves the Stoplight Elements web component, loaded from jsdelivr unless ScriptURL and StyleURL
+// point to self hosted files
+type StoplightElements struct {
+	Title     string
+	ScriptURL string
+	StyleURL  string
+}
+
+type uiPage struct {
+	Title     string
+	SpecURL   string
+	AssetsURL string
+	ScriptURL string
+	StyleURL  string
+}
+
+type uiHandler struct {
+	index   []byte
+	assets  http.Handler
+	modTime time.Time
+}
+
+// UIHandler returns an HTTP handler that serves the embedded Swagger UI pointing to specURL
+func UIHandler(specURL string) (http.Handler, error) {
+	return SwaggerUI{}.Handler(specURL)
+}
+
+func (ui SwaggerUI) Handler(specURL string) (http.Handler, error) {
+	assets, err := fs.Sub(swaggerUIFiles, "swaggerui/dist")
+	if err != nil {
+		return nil, err
+	}
+
+	page := uiPage{Title: ui.Title, SpecURL: specURL, AssetsURL: strings.TrimSuffix(ui.AssetsURL, "/")}
+	if len(page.AssetsURL) == 0 {
+		if !swaggerUIEmbedded() {
+			return nil, fmt.Errorf("swagger-ui-dist is not embedded in swaggerui/dist, run go generate or set SwaggerUI.AssetsURL")
+		}
+
+		page.AssetsURL = "."
+	}
+
+	return newUIHandler(swaggerUITemplate, page, http.FileServer(http.FS(assets)))
+}
+
+func (ui ReDoc) Handler(specURL string) (http.Handler, error) {
+	page := uiPage{Title: ui.Title, SpecURL: specURL, ScriptURL: withDefault(ui.ScriptURL, redocScriptURL)}
+	return newUIHandler(uiTemplates.Lookup("redoc.html"), page, nil)
+}
+
+func (ui RapiDoc) Handler(specURL string) (http.Handler, error) {
+	page := uiPage{Title: ui.Title, SpecURL: specURL, ScriptURL: withDefault(ui.ScriptURL, rapiDocScriptURL)}
+	return newUIHandler(uiTemplates.Lookup("rapidoc.html"), page, nil)
+}
+
+func (ui Scalar) Handler(specURL string) (http.Handler, error) {
+	page := uiPage{Title: ui.Title, SpecURL: specURL, ScriptURL: withDefault(ui.ScriptURL, scalarScriptURL)}
+	return newUIHandler(uiTemplates.Lookup("scalar.html"), page, nil)
+}
+
+func (ui StoplightElements) Handler(specURL string) (http.Handler, error) {
+	page := uiPage{
+		Title:     ui.Title,
+		SpecURL:   specURL,
+		ScriptURL: withDefault(ui.ScriptURL, stoplightElementsBaseURL+"/web-components.min.js"),
+		StyleURL:  withDefault(ui.StyleURL, stoplightElementsBaseURL+"/styles.min.css"),
+	}
+	return newUIHandler(uiTemplates.Lookup("elements.html"), page, nil)
+}
+
+func newUIHandler(tmpl *template.Template, page uiPage, assets http.Handler) (http.Handler, error) {
+	page.Title = withDefault(page.Title, defaultUITitle)
+	var index bytes.Buffer
+	err := tmpl.Execute(&index, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uiHandler{index: index.Bytes(), assets: assets, modTime: time.Now()}, nil
+}
+
+func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if len(path) == 0 || path == "index.html" {
+		http.ServeContent(w, r, "index.html", h.modTime, bytes.NewReader(h.index))
+		return
+	}
+
+	if h.assets == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.assets.ServeHTTP(w, r)
+}
+
+// swaggerUIEmbedded tells whether the swagger-ui-dist files were vendored into swaggerui/dist before building
+func swaggerUIEmbedded() bool {
+	_, err := fs.Stat(swaggerUIFiles, "swaggerui/dist/swagger-ui-bundle.js")
+	return err == nil
+}
+
+func withDefault(value, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+
+	return value
+}
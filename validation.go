@@ -0,0 +1,504 @@
+package summerfish
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	problemContentType        = "application/problem+json"
+	defaultValidationBodySize = 10 << 20
+)
+
+// Problem is an RFC 7807 problem details response
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   []ValidationError `json:"errors,omitempty"`
+}
+
+// ValidationError locates one value which does not match the document
+type ValidationError struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationOptions configures ValidationMiddleware.
+// In ReportOnly mode invalid requests reach the handler and are only passed to Report, which logs them by default.
+// Bodies larger than MaxBodySize, 10MB by default, are answered with 413 and fail to read in the handler.
+type ValidationOptions struct {
+	ReportOnly  bool
+	Report      func(r *http.Request, problem Problem)
+	MaxBodySize int64
+}
+
+type requestValidator struct {
+	scheme  *SchemeHolder
+	options ValidationOptions
+}
+
+// ValidationMiddleware validates the path, query and header parameters, the content type and the JSON body
+// of the requests against the built document before the handler runs, answering problem responses otherwise
+func ValidationMiddleware(s *SchemeHolder, options ValidationOptions) mux.MiddlewareFunc {
+	v := &requestValidator{scheme: s, options: options}
+	//compiling the parameter patterns up front reports the invalid ones when the middleware is added
+	forEachOperation(s, func(method, path string, operation Operation) {
+		for _, parameter := range operation.Parameters {
+			compilePattern(parameter.Pattern)
+		}
+	})
+
+	if v.options.MaxBodySize <= 0 {
+		v.options.MaxBodySize = defaultValidationBodySize
+	}
+
+	if v.options.Report == nil {
+		v.options.Report = func(r *http.Request, problem Problem) {
+			log.Printf("summerfish: %s %s: %s", r.Method, r.URL.Path, problem.Detail)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			problem, ok := v.validate(w, r)
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			v.options.Report(r, problem)
+			if v.options.ReportOnly {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writeProblem(w, problem)
+		})
+	}
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// findOperation finds the documented operation of the route matched by gorilla mux,
+// requests which didn't go through a router, e.g. in tests, are matched against the path templates
+// and the variables of the path are returned since mux.Vars has none
+func findOperation(s *SchemeHolder, r *http.Request) (operation Operation, vars map[string]string, ok bool) {
+	prefix := strings.TrimSuffix(s.BasePath, "/")
+	method := strings.ToLower(r.Method)
+	if route := mux.CurrentRoute(r); route != nil {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return
+		}
+
+		operation, ok = s.Paths[trimBasePath(template, s.BasePath)][method]
+		vars = mux.Vars(r)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		return
+	}
+
+	for _, path := range sortedKeys(s.Paths) {
+		matcher := compileTemplate(path)
+		values := matcher.regex.FindStringSubmatch(strings.TrimPrefix(r.URL.Path, prefix))
+		if values == nil {
+			continue
+		}
+
+		vars = map[string]string{}
+		for i, name := range matcher.names {
+			vars[name] = values[i+1]
+		}
+
+		operation, ok = s.Paths[path][method]
+		return
+	}
+
+	return
+}
+
+// templateMatcher matches the urls of a normalized path template, each variable capturing one segment
+type templateMatcher struct {
+	regex *regexp.Regexp
+	names []string
+}
+
+var templateMatchers = struct {
+	sync.Mutex
+	compiled map[string]templateMatcher
+}{compiled: map[string]templateMatcher{}}
+
+// compileTemplate caches the matchers since they are needed for every request which didn't go through a router.
+// The variables are captured by position because their names aren't always valid group names, e.g. {user-id}.
+func compileTemplate(template string) templateMatcher {
+	templateMatchers.Lock()
+	defer templateMatchers.Unlock()
+	if matcher, ok := templateMatchers.compiled[template]; ok {
+		return matcher
+	}
+
+	var matcher templateMatcher
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, part := range strings.SplitAfter(template, "}") {
+		start := strings.Index(part, "{")
+		if start < 0 {
+			builder.WriteString(regexp.QuoteMeta(part))
+			continue
+		}
+
+		builder.WriteString(regexp.QuoteMeta(part[:start]) + "([^/]+)")
+		matcher.names = append(matcher.names, strings.TrimSuffix(part[start+1:], "}"))
+	}
+
+	builder.WriteString("$")
+	matcher.regex = regexp.MustCompile(builder.String())
+	templateMatchers.compiled[template] = matcher
+	return matcher
+}
+
+func (v *requestValidator) validate(w http.ResponseWriter, r *http.Request) (problem Problem, ok bool) {
+	operation, vars, found := findOperation(v.scheme, r)
+	if !found {
+		return problem, true
+	}
+
+	problem = Problem{Type: "about:blank", Status: http.StatusBadRequest, Instance: r.URL.Path}
+	hasBody := false
+	for _, parameter := range operation.Parameters {
+		var value string
+		var present bool
+		switch parameter.QueryType {
+		case "path":
+			value, present = vars[parameter.Name]
+		case "query":
+			var values []string
+			values, present = r.URL.Query()[parameter.Name]
+			if present && len(values) > 0 {
+				value = values[0]
+			}
+		case "header":
+			value = r.Header.Get(parameter.Name)
+			present = len(r.Header.Values(parameter.Name)) > 0
+		case "body", "formData":
+			hasBody = true
+			continue
+		}
+
+		if !present {
+			if parameter.Required {
+				problem.add(parameter.QueryType, parameter.Name, "is required")
+			}
+
+			continue
+		}
+
+		if message := v.validateValue(value, parameter.Type, parameter.Pattern, parameter.Enum); len(message) > 0 {
+			problem.add(parameter.QueryType, parameter.Name, message)
+		}
+	}
+
+	if hasBody && len(operation.Consumes) > 0 && !isConsumed(r.Header.Get("Content-Type"), operation.Consumes) {
+		problem.Status = http.StatusUnsupportedMediaType
+		problem.add("header", "Content-Type", fmt.Sprintf("must be one of %s", strings.Join(operation.Consumes, ", ")))
+	} else if hasBody {
+		v.validateBody(w, r, operation, &problem)
+	}
+
+	if len(problem.Errors) == 0 {
+		return problem, true
+	}
+
+	problem.summarize()
+	return problem, false
+}
+
+func (problem *Problem) add(in, name, message string) {
+	problem.Errors = append(problem.Errors, ValidationError{In: in, Name: name, Message: message})
+}
+
+// summarize sets the title from the status and joins the errors into the detail
+func (problem *Problem) summarize() {
+	problem.Title = http.StatusText(problem.Status)
+	var details []string
+	for _, e := range problem.Errors {
+		details = append(details, strings.TrimSpace(e.In+" "+e.Name)+" "+e.Message)
+	}
+
+	problem.Detail = strings.Join(details, "; ")
+}
+
+func isConsumed(contentType string, consumes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return containsString(consumes, mediaType)
+}
+
+func (v *requestValidator) validateValue(value, valueType, pattern string, enum []string) string {
+	switch valueType {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be an integer"
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	}
+
+	if regex := compilePattern(pattern); regex != nil && !regex.MatchString(value) {
+		return fmt.Sprintf("must match %s", pattern)
+	}
+
+	if len(enum) > 0 && !containsString(enum, value) {
+		return fmt.Sprintf("must be one of %s", strings.Join(enum, ", "))
+	}
+
+	return ""
+}
+
+// validateBody checks the JSON body and the form fields up to the size limit, the body is restored for the handler
+func (v *requestValidator) validateBody(w http.ResponseWriter, r *http.Request, operation Operation, problem *Problem) {
+	r.Body = http.MaxBytesReader(w, r.Body, v.options.MaxBodySize)
+	if consumesForm(operation) {
+		err := r.ParseMultipartForm(v.options.MaxBodySize)
+		if err == http.ErrNotMultipart {
+			err = r.ParseForm()
+		}
+
+		if err != nil {
+			v.addReadError(err, problem)
+			return
+		}
+	}
+
+	for _, parameter := range operation.Parameters {
+		switch parameter.QueryType {
+		case "formData":
+			if !parameter.Required {
+				continue
+			}
+
+			if parameter.Type == "file" {
+				if _, _, err := r.FormFile(parameter.Name); err != nil {
+					problem.add("formData", parameter.Name, "is required")
+				}
+			} else if len(r.FormValue(parameter.Name)) == 0 {
+				problem.add("formData", parameter.Name, "is required")
+			}
+		case "body":
+			//the handler reads the content again, followed by the error of the limit when it was exceeded
+			reader := r.Body
+			content, err := ioutil.ReadAll(reader)
+			r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(content), reader), Closer: reader}
+			if err != nil {
+				v.addReadError(err, problem)
+				return
+			}
+
+			if len(bytes.TrimSpace(content)) == 0 {
+				if parameter.Required {
+					problem.add("body", "", "is required")
+				}
+
+				continue
+			}
+
+			var body interface{}
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+			if err := decoder.Decode(&body); err != nil {
+				problem.add("body", "", "must be valid JSON: "+err.Error())
+				continue
+			}
+
+			validateSchema(body, parameter.Schema, "body", "", problem)
+		}
+	}
+}
+
+func consumesForm(operation Operation) bool {
+	for _, parameter := range operation.Parameters {
+		if parameter.QueryType == "formData" {
+			return true
+		}
+	}
+
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// addReadError reports the bodies over the limit with 413 and the other read failures as bad requests
+func (v *requestValidator) addReadError(err error, problem *Problem) {
+	//http.MaxBytesError isn't available in the supported go versions, the reader returns this message instead
+	if strings.Contains(err.Error(), "request body too large") {
+		problem.Status = http.StatusRequestEntityTooLarge
+		problem.add("body", "", fmt.Sprintf("must not exceed %d bytes", v.options.MaxBodySize))
+		return
+	}
+
+	problem.add("body", "", "could not be read: "+err.Error())
+}
+
+// validateSchema checks a decoded JSON value against the types and constraints of the schema, unresolved types
+// are accepted. Null is only rejected for required properties since Go encodes nil pointers, slices and maps as null.
+func validateSchema(value interface{}, schema SchemaParameters, in, location string, problem *Problem) {
+	if value == nil {
+		return
+	}
+
+	fail := func(message string) {
+		problem.add(in, strings.TrimPrefix(location, "."), message)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+
+		for _, name := range schema.Required {
+			if property, ok := object[name]; !ok {
+				problem.add(in, strings.TrimPrefix(location+"."+name, "."), "is required")
+			} else if property == nil {
+				problem.add(in, strings.TrimPrefix(location+"."+name, "."), "must not be null")
+			}
+		}
+
+		for _, name := range sortedKeys(schema.Properties) {
+			if property, ok := object[name]; ok {
+				validateSchema(property, schema.Properties[name], in, location+"."+name, problem)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+
+		for i, item := range array {
+			if schema.Items != nil {
+				validateSchema(item, *schema.Items, in, fmt.Sprintf("%s[%d]", location, i), problem)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+
+		length := utf8.RuneCountInString(text)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail(fmt.Sprintf("must be at least %d characters long", *schema.MinLength))
+		}
+
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail(fmt.Sprintf("must be at most %d characters long", *schema.MaxLength))
+		}
+
+		if regex := compilePattern(schema.Pattern); regex != nil && !regex.MatchString(text) {
+			fail(fmt.Sprintf("must match %s", schema.Pattern))
+		}
+	case "number", "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be a " + schema.Type)
+			return
+		}
+
+		if _, err := number.Int64(); err != nil && schema.Type == "integer" {
+			fail("must be an integer")
+			return
+		}
+
+		parsed, _ := number.Float64()
+		if schema.Minimum != nil && parsed < *schema.Minimum {
+			fail(fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+
+		if schema.Maximum != nil && parsed > *schema.Maximum {
+			fail(fmt.Sprintf("must be at most %v", *schema.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	//enums are documented as strings whatever the type, so the scalars are compared in their JSON form
+	if len(schema.Enum) > 0 {
+		switch value.(type) {
+		case string, json.Number, bool:
+			if !containsString(schema.Enum, fmt.Sprint(value)) {
+				fail(fmt.Sprintf("must be one of %s", strings.Join(schema.Enum, ", ")))
+			}
+		}
+	}
+}
+
+var compiledPatterns = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: map[string]*regexp.Regexp{}}
+
+// compilePattern caches the compiled patterns of the parameters and schemas, it returns nil for empty patterns
+// and the ones that aren't valid RE2 syntax, which are logged the first time they are seen and not checked
+func compilePattern(pattern string) *regexp.Regexp {
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	compiledPatterns.Lock()
+	defer compiledPatterns.Unlock()
+	regex, ok := compiledPatterns.compiled[pattern]
+	if !ok {
+		var err error
+		regex, err = regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("summerfish: pattern %s is not checked: %s", pattern, err)
+		}
+
+		compiledPatterns.compiled[pattern] = regex
+	}
+
+	return regex
+}